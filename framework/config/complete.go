@@ -47,6 +47,27 @@ func completeCronTask(c *Config) {
 	if c.CronTask.NodeID == "" {
 		c.CronTask.NodeID = c.App.Name
 	}
+	if c.CronTask.TimeoutGracePeriod == 0 {
+		c.CronTask.TimeoutGracePeriod = 10 * time.Second
+	}
+	if c.CronTask.ClaimRetention == 0 {
+		c.CronTask.ClaimRetention = 7 * 24 * time.Hour
+	}
+	if c.CronTask.LockLeaseTTL == 0 {
+		c.CronTask.LockLeaseTTL = 30 * time.Second
+	}
+	if c.CronTask.PollInterval == 0 {
+		c.CronTask.PollInterval = 2 * time.Second
+	}
+	if c.CronTask.QueueTimeout == 0 {
+		c.CronTask.QueueTimeout = 30 * time.Second
+	}
+	if c.CronTask.NodeStaleTimeout == 0 {
+		c.CronTask.NodeStaleTimeout = 10 * time.Minute
+	}
+	if c.CronTask.LockRenewInterval == 0 || c.CronTask.LockRenewInterval >= c.CronTask.LockLeaseTTL {
+		c.CronTask.LockRenewInterval = c.CronTask.LockLeaseTTL / 3
+	}
 }
 
 func completeDatabases(c *Config) {
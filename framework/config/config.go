@@ -54,6 +54,12 @@ func GetExpireTime() time.Time {
 func GetBasePath() string {
 	return _config.Server.BashPath
 }
+func GetAppName() string {
+	return _config.App.Name
+}
+func GetAppVersion() string {
+	return _config.App.Version
+}
 func getExecPath(relativeFilePath string) (execPath string, err error) {
 	ep, e := os.Executable()
 	if e != nil {
@@ -11,11 +11,12 @@ type Config struct {
 }
 
 type AppConfig struct {
-	Name   string `yaml:"name,omitempty"`
-	Center string `yaml:"center,omitempty"`
-	Group  string `yaml:"group,omitempty"`
-	Id     string `yaml:"id,omitempty"`
-	WorkID uint16 `yaml:"workID,omitempty"`
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Center  string `yaml:"center,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+	Id      string `yaml:"id,omitempty"`
+	WorkID  uint16 `yaml:"workID,omitempty"`
 }
 
 type ServerConfig struct {
@@ -30,10 +31,20 @@ type ServerConfig struct {
 }
 
 type TaskConfig struct {
-	NodeID                 string        `yaml:"node_id,omitempty"`
-	LogLevel               string        `yaml:"log_level,omitempty"`
-	TaskSyncInterval       time.Duration `yaml:"task_sync_interval,omitempty"`
-	NotRecordTaskExecution bool          `yaml:"not_record_task_execution"`
+	NodeID                  string         `yaml:"node_id,omitempty"` // 集群内每个节点须唯一，默认为应用名，多节点部署时需单独配置
+	LogLevel                string         `yaml:"log_level,omitempty"`
+	TaskSyncInterval        time.Duration  `yaml:"task_sync_interval,omitempty"`
+	NotRecordTaskExecution  bool           `yaml:"not_record_task_execution"`
+	TimeoutGracePeriod      time.Duration  `yaml:"timeout_grace_period,omitempty"`      // 任务超时后等待任务函数退出的时间
+	RecordLockConflict      bool           `yaml:"record_lock_conflict"`                // 抢占失败的节点是否登记lock_conflict执行记录
+	ClaimRetention          time.Duration  `yaml:"claim_retention,omitempty"`           // 抢占记录保留时间
+	LockLeaseTTL            time.Duration  `yaml:"lock_lease_ttl,omitempty"`            // 任务锁租约时长，执行期间定期续期
+	LockRenewInterval       time.Duration  `yaml:"lock_renew_interval,omitempty"`       // 任务锁续期间隔
+	PollInterval            time.Duration  `yaml:"poll_interval,omitempty"`             // 轮询取消请求等集群指令的间隔
+	MaxConcurrentExecutions int            `yaml:"max_concurrent_executions,omitempty"` // 本节点同时执行的任务数上限，0为不限制
+	GroupConcurrency        map[string]int `yaml:"group_concurrency,omitempty"`         // 按任务分组限制本节点同时执行数
+	QueueTimeout            time.Duration  `yaml:"queue_timeout,omitempty"`             // 执行数已满时的最长排队时间
+	NodeStaleTimeout        time.Duration  `yaml:"node_stale_timeout,omitempty"`        // 节点超过该时长未心跳时从节点表清理
 }
 
 type LoggerConfig struct {
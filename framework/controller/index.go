@@ -10,4 +10,31 @@ func RegisterRouter(router *gin.RouterGroup) {
 	engine.POST("/getMenus", GetMenus)
 	engine.POST("/login", Login)
 	engine.POST("/getRoles", GetRoles)
+
+	registerTaskRouter(router.Group("/task"))
+}
+
+func registerTaskRouter(engine *gin.RouterGroup) {
+	engine.POST("/getTasks", GetTasks)
+	engine.POST("/pause", PauseTask)
+	engine.POST("/resume", ResumeTask)
+	engine.POST("/getExecutions", GetExecutions)
+	engine.POST("/getLockedTasks", GetLockedTasks)
+	engine.POST("/forceUnlock", ForceUnlock)
+	engine.POST("/cancelExecution", CancelExecution)
+	engine.POST("/trigger", TriggerTask)
+	engine.POST("/getClusterControl", GetClusterControl)
+	engine.POST("/suspendCluster", SuspendCluster)
+	engine.POST("/resumeCluster", ResumeCluster)
+	engine.POST("/getMaintenanceWindows", GetMaintenanceWindows)
+	engine.POST("/getCalendars", GetCalendars)
+	engine.POST("/getCalendarDays", GetCalendarDays)
+	engine.POST("/importCalendar", ImportCalendar)
+	engine.POST("/getDependencies", GetDependencies)
+	engine.POST("/saveDependencies", SaveDependencies)
+	engine.POST("/getDagRuns", GetDagRuns)
+	engine.POST("/getWorkerStats", GetWorkerStats)
+	engine.POST("/getShards", GetShards)
+	engine.POST("/retryShard", RetryShard)
+	engine.POST("/getNodes", GetNodes)
 }
@@ -0,0 +1,476 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hawthorntrees/cronframework/framework/cron"
+	"github.com/hawthorntrees/cronframework/framework/dbs"
+	"github.com/hawthorntrees/cronframework/framework/dto/resp"
+	"github.com/hawthorntrees/cronframework/framework/dto/task"
+	"github.com/hawthorntrees/cronframework/framework/logger"
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func GetExecutions(c *gin.Context) {
+	query := task.ExecutionQuery{}
+	if err := c.ShouldBindJSON(&query); err != nil {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 500 {
+		query.Size = 20
+	}
+
+	db := dbs.GetDB().WithContext(c).Model(&model.Hawthorn_task_execution{})
+	if query.TaskID > 0 {
+		db = db.Where("task_id = ?", query.TaskID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.TraceID != "" {
+		db = db.Where("trace_id = ?", query.TraceID)
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		resp.Error(c, "查询执行记录失败:"+err.Error())
+		return
+	}
+	var executions []model.Hawthorn_task_execution
+	err := db.Order("start_time desc").Offset((query.Page - 1) * query.Size).Limit(query.Size).Find(&executions).Error
+	if err != nil {
+		resp.Error(c, "查询执行记录失败:"+err.Error())
+		return
+	}
+
+	data := make([]task.ExecutionDto, 0, len(executions))
+	for _, execution := range executions {
+		data = append(data, task.NewExecutionDto(execution))
+	}
+	resp.Success(c, &resp.PageResult{
+		Data:  data,
+		Total: total,
+	})
+}
+
+func GetLockedTasks(c *gin.Context) {
+	tasks, err := cron.NewRepository().GetLockedTasks(c)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, tasks)
+}
+
+func ForceUnlock(c *gin.Context) {
+	req := struct {
+		TaskID int64  `json:"task_id"`
+		Slot   *int   `json:"slot"` // 只释放允许并发的任务的某个执行槽位
+		Reason string `json:"reason"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	op := &model.Hawthorn_task_operation{
+		TaskID:   req.TaskID,
+		Action:   cron.ActionForceUnlock,
+		Operator: c.GetString("user_id"),
+		Detail:   req.Reason,
+		TraceID:  resp.GetTraceIDFromContext(c),
+	}
+	owner, err := cron.NewRepository().ForceUnlockTask(c, op, req.Slot)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Error(c, "任务不存在")
+			return
+		}
+		resp.Error(c, err.Error())
+		return
+	}
+	logger.GetLogger(c).Warn("强制释放任务锁",
+		zap.Int64("taskID", req.TaskID),
+		zap.String("owner", owner),
+		zap.String("operator", op.Operator))
+	resp.Success(c, resp.RespJson{"locked_by": owner})
+}
+
+func CancelExecution(c *gin.Context) {
+	req := struct {
+		TraceID string `json:"trace_id"`
+		Reason  string `json:"reason"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TraceID == "" {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if err := cron.GetTaskManager().Cancel(c, req.TraceID, req.Reason); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "已提交取消请求")
+}
+
+func TriggerTask(c *gin.Context) {
+	req := struct {
+		TaskID int64           `json:"task_id"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	trigger, err := cron.GetTaskManager().Trigger(c, req.TaskID, req.Params)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, trigger, "已提交执行")
+}
+
+// nextRunCount 任务列表中展示的后续触发次数
+const nextRunCount = 5
+
+func GetTasks(c *gin.Context) {
+	query := task.TaskQuery{}
+	if err := c.ShouldBindJSON(&query); err != nil {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 500 {
+		query.Size = 20
+	}
+
+	db := dbs.GetDB().WithContext(c).Model(&model.Hawthorn_task{})
+	if query.Name != "" {
+		db = db.Where("name like ?", "%"+query.Name+"%")
+	}
+	if query.Paused != nil {
+		db = db.Where("paused = ?", *query.Paused)
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		resp.Error(c, "查询任务失败:"+err.Error())
+		return
+	}
+	var tasks []model.Hawthorn_task
+	if err := db.Order("id").Offset((query.Page - 1) * query.Size).Limit(query.Size).Find(&tasks).Error; err != nil {
+		resp.Error(c, "查询任务失败:"+err.Error())
+		return
+	}
+
+	now := time.Now()
+	data := make([]task.TaskDto, 0, len(tasks))
+	for _, t := range tasks {
+		nextRunTimes, err := cron.GetTaskManager().NextRunTimes(&t, now, nextRunCount)
+		data = append(data, task.NewTaskDto(t, nextRunTimes, err))
+	}
+	resp.Success(c, &resp.PageResult{
+		Data:  data,
+		Total: total,
+	})
+}
+
+func PauseTask(c *gin.Context) {
+	req := struct {
+		TaskID   int64      `json:"task_id"`
+		Reason   string     `json:"reason"`
+		ResumeAt *time.Time `json:"resume_at"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if req.ResumeAt != nil && req.ResumeAt.Before(time.Now()) {
+		resp.Error(c, "自动恢复时间不能早于当前时间")
+		return
+	}
+	if err := cron.GetTaskManager().Pause(c, req.TaskID, req.Reason, req.ResumeAt); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "任务已暂停")
+}
+
+func ResumeTask(c *gin.Context) {
+	req := struct {
+		TaskID int64 `json:"task_id"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if err := cron.GetTaskManager().Resume(c, req.TaskID); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "任务已恢复")
+}
+
+func GetClusterControl(c *gin.Context) {
+	control, err := cron.GetTaskManager().GetClusterControl(c)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, control)
+}
+
+func SuspendCluster(c *gin.Context) {
+	req := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if err := cron.GetTaskManager().SuspendCluster(c, req.Reason); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "集群调度已暂停")
+}
+
+func ResumeCluster(c *gin.Context) {
+	if err := cron.GetTaskManager().ResumeCluster(c); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "集群调度已恢复")
+}
+
+func GetMaintenanceWindows(c *gin.Context) {
+	var windows []model.Hawthorn_maintenance_window
+	if err := dbs.GetDB().WithContext(c).Order("id").Find(&windows).Error; err != nil {
+		resp.Error(c, "查询维护窗口失败:"+err.Error())
+		return
+	}
+	resp.Success(c, windows)
+}
+
+func GetCalendars(c *gin.Context) {
+	var calendars []model.Hawthorn_calendar
+	if err := dbs.GetDB().WithContext(c).Order("id").Find(&calendars).Error; err != nil {
+		resp.Error(c, "查询日历失败:"+err.Error())
+		return
+	}
+	resp.Success(c, calendars)
+}
+
+func GetCalendarDays(c *gin.Context) {
+	req := struct {
+		CalendarID int64 `json:"calendar_id"`
+		Year       int   `json:"year"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.CalendarID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	db := dbs.GetDB().WithContext(c).Where("calendar_id = ?", req.CalendarID)
+	if req.Year > 0 {
+		db = db.Where("date >= ? and date < ?",
+			time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(req.Year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	var days []model.Hawthorn_calendar_day
+	if err := db.Order("date").Find(&days).Error; err != nil {
+		resp.Error(c, "查询日历日期失败:"+err.Error())
+		return
+	}
+	resp.Success(c, days)
+}
+
+// ImportCalendar 上传CSV或ICS文件导入日历，表单字段：name、description、weekends、file
+func ImportCalendar(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	fileHeader, err := c.FormFile("file")
+	if err != nil || name == "" {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		resp.Error(c, "读取文件失败:"+err.Error())
+		return
+	}
+	defer file.Close()
+
+	var days []*model.Hawthorn_calendar_day
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv":
+		days, err = cron.ParseCalendarCSV(file)
+	case ".ics":
+		days, err = cron.ParseCalendarICS(file)
+	default:
+		resp.Error(c, "仅支持CSV或ICS文件")
+		return
+	}
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	calendar := &model.Hawthorn_calendar{
+		Name:        name,
+		Description: c.PostForm("description"),
+		Weekends:    c.PostForm("weekends"),
+	}
+	if err := cron.GetTaskManager().ImportCalendar(c, calendar, days); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, fmt.Sprintf("已导入%d个日期", len(days)))
+}
+
+func GetDependencies(c *gin.Context) {
+	req := struct {
+		TaskID int64 `json:"task_id"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	var deps []model.Hawthorn_task_dependency
+	err := dbs.GetDB().WithContext(c).Where("task_id = ? or upstream_id = ?", req.TaskID, req.TaskID).Order("id").Find(&deps).Error
+	if err != nil {
+		resp.Error(c, "查询任务依赖失败:"+err.Error())
+		return
+	}
+	resp.Success(c, deps)
+}
+
+func SaveDependencies(c *gin.Context) {
+	req := struct {
+		TaskID    int64                             `json:"task_id"`
+		Upstreams []*model.Hawthorn_task_dependency `json:"upstreams"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if err := cron.GetTaskManager().SaveDependencies(c, req.TaskID, req.Upstreams); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "任务依赖已保存")
+}
+
+// GetDagRuns 查询任务所在DAG最近几次运行，按计划触发时间分组
+func GetDagRuns(c *gin.Context) {
+	query := task.DagQuery{}
+	if err := c.ShouldBindJSON(&query); err != nil || query.TaskID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if query.Size <= 0 || query.Size > 50 {
+		query.Size = 10
+	}
+	deps, err := cron.NewRepository().GetDependencies(c)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	ids := cron.DagTaskIDs(deps, query.TaskID)
+	idSet := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		idSet[id] = true
+	}
+
+	dag := task.DagDto{}
+	for _, dep := range deps {
+		if idSet[dep.TaskID] {
+			dag.Edges = append(dag.Edges, *dep)
+		}
+	}
+	db := dbs.GetDB().WithContext(c)
+	if err := db.Model(&model.Hawthorn_task{}).Where("id in ?", ids).Order("id").Find(&dag.Tasks).Error; err != nil {
+		resp.Error(c, "查询任务失败:"+err.Error())
+		return
+	}
+	var scheduledAts []time.Time
+	err = db.Model(&model.Hawthorn_task_execution{}).
+		Where("task_id in ? and scheduled_at is not null", ids).
+		Distinct("scheduled_at").Order("scheduled_at desc").Limit(query.Size).
+		Pluck("scheduled_at", &scheduledAts).Error
+	if err != nil {
+		resp.Error(c, "查询执行记录失败:"+err.Error())
+		return
+	}
+	var executions []model.Hawthorn_task_execution
+	if len(scheduledAts) > 0 {
+		err = db.Where("task_id in ? and scheduled_at in ?", ids, scheduledAts).Order("start_time").Find(&executions).Error
+		if err != nil {
+			resp.Error(c, "查询执行记录失败:"+err.Error())
+			return
+		}
+	}
+	for _, scheduledAt := range scheduledAts {
+		run := task.DagRun{ScheduledAt: scheduledAt}
+		for _, execution := range executions {
+			if execution.ScheduledAt != nil && execution.ScheduledAt.Equal(scheduledAt) {
+				run.Executions = append(run.Executions, task.NewExecutionDto(execution))
+			}
+		}
+		dag.Runs = append(dag.Runs, run)
+	}
+	resp.Success(c, dag)
+}
+
+// GetShards 查询分片任务一次执行的各分片状态
+func GetShards(c *gin.Context) {
+	req := struct {
+		TraceID string `json:"trace_id"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TraceID == "" {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	shards, err := cron.NewRepository().GetShards(c, req.TraceID)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, shards)
+}
+
+func RetryShard(c *gin.Context) {
+	req := struct {
+		ShardID int64 `json:"shard_id"`
+	}{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ShardID <= 0 {
+		resp.Error(c, "请求参数错误")
+		return
+	}
+	if err := cron.GetTaskManager().RetryShard(c, req.ShardID); err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nil, "已提交重试")
+}
+
+// GetNodes 查询集群节点及其注册的任务函数
+func GetNodes(c *gin.Context) {
+	nodes, err := cron.GetTaskManager().GetNodes(c)
+	if err != nil {
+		resp.Error(c, err.Error())
+		return
+	}
+	resp.Success(c, nodes)
+}
+
+func GetWorkerStats(c *gin.Context) {
+	resp.Success(c, cron.GetTaskManager().WorkerStats())
+}
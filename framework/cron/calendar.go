@@ -0,0 +1,349 @@
+package cron
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	CalendarSkip  = "skip"  // 非工作日不执行
+	CalendarShift = "shift" // 顺延到下一个工作日的同一时刻
+	CalendarRun   = "run"   // 忽略日历照常执行
+)
+
+const (
+	DayHoliday = "holiday" // 节假日，不是工作日
+	DayWorkday = "workday" // 调休工作日，休息日照常上班
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// maxCalendarScan 向后查找工作日的最大天数，以及跳过非工作日重新计算的最大次数
+	maxCalendarScan = 366
+)
+
+var ErrCalendarNotFound = errors.New("日历不存在")
+
+// businessCalendar 解析后的工作日历
+type businessCalendar struct {
+	weekends uint8 // 按位表示周日到周六
+	days     map[string]string
+}
+
+func newBusinessCalendar(c *model.Hawthorn_calendar, days []*model.Hawthorn_calendar_day) (*businessCalendar, error) {
+	weekends, err := parseWeekdays(c.Weekends)
+	if err != nil {
+		return nil, err
+	}
+	bc := &businessCalendar{
+		weekends: weekends,
+		days:     make(map[string]string, len(days)),
+	}
+	for _, day := range days {
+		bc.days[day.Date.Format(dateLayout)] = day.DayType
+	}
+	return bc, nil
+}
+
+// parseWeekdays 解析逗号分隔的星期，0为周日
+func parseWeekdays(s string) (uint8, error) {
+	var weekdays uint8
+	for _, day := range strings.Split(s, ",") {
+		day = strings.TrimSpace(day)
+		if day == "" {
+			continue
+		}
+		d, err := strconv.Atoi(day)
+		if err != nil || d < 0 || d > 6 {
+			return 0, fmt.Errorf("星期[%s]错误", day)
+		}
+		weekdays |= 1 << d
+	}
+	return weekdays, nil
+}
+
+// isBusinessDay 按t所在时区的日期判断是否为工作日
+func (c *businessCalendar) isBusinessDay(t time.Time) bool {
+	switch c.days[t.Format(dateLayout)] {
+	case DayHoliday:
+		return false
+	case DayWorkday:
+		return true
+	}
+	return c.weekends&(1<<t.Weekday()) == 0
+}
+
+// nextBusinessDay t之后第一个工作日的同一时刻
+func (c *businessCalendar) nextBusinessDay(t time.Time) (time.Time, bool) {
+	y, mon, d := t.Date()
+	for i := 1; i <= maxCalendarScan; i++ {
+		next := time.Date(y, mon, d+i, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+		if c.isBusinessDay(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// calendarSchedule 按工作日历过滤或顺延触发时间，日历在每次计算时按名称获取，同步后立即生效
+type calendarSchedule struct {
+	cron.Schedule
+	name   string
+	policy string
+	lookup func(name string) *businessCalendar
+}
+
+func (s *calendarSchedule) Next(t time.Time) time.Time {
+	cal := s.lookup(s.name)
+	next := s.Schedule.Next(t)
+	if cal == nil {
+		return next
+	}
+	for i := 0; i < maxCalendarScan && !next.IsZero(); i++ {
+		if cal.isBusinessDay(next) {
+			return next
+		}
+		if s.policy == CalendarShift {
+			shifted, _ := cal.nextBusinessDay(next)
+			return shifted
+		}
+		// 按天跳过非工作日，从下一个工作日零点起重新计算，避免高频任务逐次遍历
+		y, mon, d := next.Date()
+		day, ok := cal.nextBusinessDay(time.Date(y, mon, d, 0, 0, 0, 0, next.Location()))
+		if !ok {
+			break
+		}
+		next = s.Schedule.Next(day.Add(-time.Nanosecond))
+	}
+	return time.Time{}
+}
+
+// parseSchedule 在cron表达式的基础上应用任务配置的工作日历及生效时间
+func (m *TaskManager) parseSchedule(task *model.Hawthorn_task) (cron.Schedule, error) {
+	sched, err := parseSchedule(task)
+	if err != nil {
+		return nil, err
+	}
+	if task.Calendar == "" || task.CalendarPolicy == CalendarRun {
+		return newBoundedSchedule(sched, task)
+	}
+	if task.CalendarPolicy != "" && task.CalendarPolicy != CalendarSkip && task.CalendarPolicy != CalendarShift {
+		return nil, fmt.Errorf("日历策略[%s]错误", task.CalendarPolicy)
+	}
+	if m.getCalendar(task.Calendar) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCalendarNotFound, task.Calendar)
+	}
+	return newBoundedSchedule(&calendarSchedule{
+		Schedule: sched,
+		name:     task.Calendar,
+		policy:   task.CalendarPolicy,
+		lookup:   m.getCalendar,
+	}, task)
+}
+
+// NextRunTimes 计算任务从from开始的后n次计划触发时间，时间处于任务时区
+func (m *TaskManager) NextRunTimes(task *model.Hawthorn_task, from time.Time, n int) ([]time.Time, error) {
+	if !hasSchedule(task) {
+		return nil, nil
+	}
+	sched, err := m.parseSchedule(task)
+	if err != nil {
+		return nil, err
+	}
+	loc, _ := taskLocation(task)
+	times := make([]time.Time, 0, n)
+	t := from
+	for i := 0; i < n; i++ {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t.In(loc))
+	}
+	return times, nil
+}
+
+func (m *TaskManager) getCalendar(name string) *businessCalendar {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.calendars[name]
+}
+
+// loadCalendars 同步工作日历配置
+func (m *TaskManager) loadCalendars() {
+	calendars, days, err := m.repo.GetCalendars(m.ctx)
+	if err != nil {
+		m.logger.Errorf("%v", err)
+		return
+	}
+	parsed := make(map[string]*businessCalendar, len(calendars))
+	for _, c := range calendars {
+		bc, err := newBusinessCalendar(c, days[c.ID])
+		if err != nil {
+			m.logger.Errorf("日历[%v-%v]配置错误:%v", c.ID, c.Name, err)
+			continue
+		}
+		parsed[c.Name] = bc
+	}
+	m.mu.Lock()
+	m.calendars = parsed
+	m.mu.Unlock()
+}
+
+// ImportCalendar 导入日历的例外日期，日历不存在时新建
+func (m *TaskManager) ImportCalendar(ctx context.Context, calendar *model.Hawthorn_calendar, days []*model.Hawthorn_calendar_day) error {
+	if calendar.Weekends == "" {
+		calendar.Weekends = "0,6"
+	}
+	if _, err := parseWeekdays(calendar.Weekends); err != nil {
+		return err
+	}
+	operator := contextString(ctx, "user_id")
+	op := &model.Hawthorn_task_operation{
+		Action:   ActionImportCalendar,
+		Operator: operator,
+		Detail:   fmt.Sprintf("%s:%d", calendar.Name, len(days)),
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	if err := m.repo.ImportCalendar(ctx, calendar, days, op); err != nil {
+		return err
+	}
+	m.logger.Infof("%s导入日历[%s]%d个日期", operator, calendar.Name, len(days))
+	m.loadCalendars()
+	return nil
+}
+
+// ParseCalendarCSV 解析CSV格式的日期，每行为：日期(yyyy-mm-dd),类型(holiday/workday，默认holiday),名称
+func ParseCalendarCSV(r io.Reader) ([]*model.Hawthorn_calendar_day, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV格式错误: %w", err)
+	}
+	days := make(map[string]*model.Hawthorn_calendar_day)
+	for i, record := range records {
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		date, err := time.Parse(dateLayout, strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff")))
+		if err != nil {
+			if i == 0 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("第%d行日期[%s]格式错误", i+1, record[0])
+		}
+		day := &model.Hawthorn_calendar_day{Date: date, DayType: DayHoliday}
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			day.DayType = strings.ToLower(strings.TrimSpace(record[1]))
+			if day.DayType != DayHoliday && day.DayType != DayWorkday {
+				return nil, fmt.Errorf("第%d行类型[%s]错误", i+1, record[1])
+			}
+		}
+		if len(record) > 2 {
+			day.Name = strings.TrimSpace(record[2])
+		}
+		days[date.Format(dateLayout)] = day
+	}
+	return sortedDays(days), nil
+}
+
+// ParseCalendarICS 解析ICS文件中的全天事件，事件覆盖的日期均记为节假日
+func ParseCalendarICS(r io.Reader) ([]*model.Hawthorn_calendar_day, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[string]*model.Hawthorn_calendar_day)
+	var start, end time.Time
+	var summary string
+	inEvent := false
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end, summary = time.Time{}, time.Time{}, ""
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("事件[%s]缺少DTSTART", summary)
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				days[d.Format(dateLayout)] = &model.Hawthorn_calendar_day{Date: d, DayType: DayHoliday, Name: summary}
+			}
+		case !inEvent:
+		case name == "DTSTART":
+			if start, err = parseICSDate(value); err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			if end, err = parseICSDate(value); err != nil {
+				return nil, err
+			}
+		case name == "SUMMARY":
+			summary = value
+		}
+	}
+	return sortedDays(days), nil
+}
+
+// unfoldICS 读取ICS内容行，合并以空白开头的续行
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取ICS失败: %w", err)
+	}
+	return lines, nil
+}
+
+// parseICSDate 取日期部分，支持20240101及20240101T000000Z
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("ICS日期[%s]格式错误", value)
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ICS日期[%s]格式错误", value)
+	}
+	return t, nil
+}
+
+func sortedDays(days map[string]*model.Hawthorn_calendar_day) []*model.Hawthorn_calendar_day {
+	ret := make([]*model.Hawthorn_calendar_day, 0, len(days))
+	for _, day := range days {
+		ret = append(ret, day)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Date.Before(ret[j].Date)
+	})
+	return ret
+}
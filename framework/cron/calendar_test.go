@@ -0,0 +1,69 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarScheduleNext(t *testing.T) {
+	// 2026-10-01至10-07为节假日，10-10（周六）调休上班
+	cal := &businessCalendar{
+		weekends: 1<<time.Sunday | 1<<time.Saturday,
+		days: map[string]string{
+			"2026-10-10": DayWorkday,
+		},
+	}
+	for d := 1; d <= 7; d++ {
+		cal.days[time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC).Format(dateLayout)] = DayHoliday
+	}
+	date := func(m time.Month, d, h, mi, sec int) time.Time {
+		return time.Date(2026, m, d, h, mi, sec, 0, time.Local)
+	}
+	tests := []struct {
+		name   string
+		expr   string
+		policy string
+		from   time.Time
+		want   time.Time
+	}{
+		{"每分钟跨周末", "0 * * * * *", CalendarSkip, date(3, 6, 23, 59, 30), date(3, 9, 0, 0, 0)},
+		{"每小时跨周末", "0 0 * * * *", CalendarSkip, date(3, 6, 23, 30, 0), date(3, 9, 0, 0, 0)},
+		{"每秒跨周末", "* * * * * *", CalendarSkip, date(3, 7, 12, 0, 0), date(3, 9, 0, 0, 0)},
+		{"每分钟跨长假", "0 * * * * *", CalendarSkip, date(9, 30, 23, 59, 30), date(10, 8, 0, 0, 0)},
+		{"每小时跨长假", "0 30 * * * *", CalendarSkip, date(9, 30, 23, 30, 0), date(10, 8, 0, 30, 0)},
+		{"调休的周六执行", "0 * * * * *", CalendarSkip, date(10, 9, 23, 59, 30), date(10, 10, 0, 0, 0)},
+		{"调休周六之后的周日跳过", "0 0 9 * * *", CalendarSkip, date(10, 10, 10, 0, 0), date(10, 12, 9, 0, 0)},
+		{"工作日内正常执行", "0 * * * * *", CalendarSkip, date(3, 9, 10, 0, 30), date(3, 9, 10, 1, 0)},
+		{"只在周末的计划顺延", "0 0 9 * * 6", CalendarShift, date(3, 1, 0, 0, 0), date(3, 9, 9, 0, 0)},
+		{"每天顺延到长假后", "0 0 9 * * *", CalendarShift, date(9, 30, 10, 0, 0), date(10, 8, 9, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := scheduleParser.Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs := &calendarSchedule{
+				Schedule: sched,
+				name:     "test",
+				policy:   tt.policy,
+				lookup:   func(string) *businessCalendar { return cal },
+			}
+			if got := cs.Next(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("%s.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarScheduleNeverBusiness(t *testing.T) {
+	cal := &businessCalendar{weekends: 1<<time.Sunday | 1<<time.Saturday, days: map[string]string{}}
+	sched, err := scheduleParser.Parse("0 0 9 * * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := &calendarSchedule{Schedule: sched, name: "test", policy: CalendarSkip, lookup: func(string) *businessCalendar { return cal }}
+	if got := cs.Next(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)); !got.IsZero() {
+		t.Fatalf("got %v, want zero", got)
+	}
+}
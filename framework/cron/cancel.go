@@ -0,0 +1,126 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+var (
+	errCancelled           = errors.New("任务已被取消")
+	ErrExecutionNotRunning = errors.New("执行记录不存在或已结束")
+)
+
+// runningExecution 本节点正在执行的任务
+type runningExecution struct {
+	TaskID    int64
+	TraceID   string
+	StartTime time.Time
+	cancel    context.CancelCauseFunc
+}
+
+func (m *TaskManager) registerRunning(exec *runningExecution) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	m.running[exec.TraceID] = exec
+}
+
+func (m *TaskManager) unregisterRunning(traceID string) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	delete(m.running, traceID)
+}
+
+func (m *TaskManager) runningTraceIDs() []string {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	ids := make([]string, 0, len(m.running))
+	for id := range m.running {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (m *TaskManager) cancelRunning(traceID string, cause error) bool {
+	m.runMu.Lock()
+	exec, ok := m.running[traceID]
+	m.runMu.Unlock()
+	if ok {
+		exec.cancel(cause)
+	}
+	return ok
+}
+
+// Cancel 请求取消正在执行的任务或分片。请求按追踪号单独登记，执行节点轮询到后取消任务上下文，
+// 任务函数需响应ctx.Done()才能及时结束。
+func (m *TaskManager) Cancel(ctx context.Context, traceID string, reason string) error {
+	operator := contextString(ctx, "user_id")
+	taskID, err := m.repo.RequestCancel(ctx, traceID, operator)
+	if errors.Is(err, ErrExecutionNotRunning) && m.cancelRunning(traceID, errCancelled) {
+		m.logger.Infof("%s取消本节点执行中的任务[%s]", operator, traceID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := m.repo.CreateOperation(ctx, &model.Hawthorn_task_operation{
+		TaskID:   taskID,
+		Action:   ActionCancel,
+		Operator: operator,
+		Detail:   "traceID:" + traceID + " " + reason,
+		TraceID:  contextString(ctx, "traceID"),
+	}); err != nil {
+		m.logger.Errorf("登记取消操作记录失败:%v", err)
+	}
+	if m.cancelRunning(traceID, errCancelled) {
+		m.logger.Infof("已取消本节点执行中的任务[%v-%s]", taskID, traceID)
+	}
+	return nil
+}
+
+// pollCancels 检查本节点执行中的任务是否被请求取消
+func (m *TaskManager) pollCancels() {
+	ids := m.runningTraceIDs()
+	if len(ids) == 0 {
+		return
+	}
+	requested, err := m.repo.GetCancelRequested(m.ctx, ids)
+	if err != nil {
+		m.logger.Errorf("查询任务取消请求失败:%v", err)
+		return
+	}
+	for _, traceID := range requested {
+		if m.cancelRunning(traceID, errCancelled) {
+			m.logger.Infof("收到取消请求，取消执行中的任务[%s]", traceID)
+		}
+	}
+}
+
+func (m *TaskManager) startPollLoop() {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.pollCancels()
+			m.pollTriggers()
+			m.pollShards()
+		}
+	}
+}
+
+func isCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errCancelled)
+}
+
+func contextString(ctx context.Context, key string) string {
+	if v, ok := ctx.Value(key).(string); ok {
+		return v
+	}
+	return ""
+}
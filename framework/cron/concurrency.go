@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	ConcurrencyForbid  = "forbid"  // 上次执行未结束时跳过本次，记为skipped
+	ConcurrencyAllow   = "allow"   // 集群内最多同时执行max_concurrency个
+	ConcurrencyReplace = "replace" // 取消执行中的任务后重新执行
+)
+
+const (
+	replacedReason = "被新的执行替换"
+	// replaceCheckInterval 等待原执行释放任务锁的检查间隔
+	replaceCheckInterval = 500 * time.Millisecond
+)
+
+// acquireLock 按任务的并发策略获取执行权，执行权已被占用时返回gorm.ErrRecordNotFound，
+// 任务已暂停或停用时返回ErrTaskPaused、ErrTaskNotFound；不限制并发时返回nil锁
+func (m *TaskManager) acquireLock(ctx context.Context, task *model.Hawthorn_task, owner string, lg *zap.Logger) (*taskLock, error) {
+	var lock *taskLock
+	var err error
+	switch task.ConcurrencyPolicy {
+	case ConcurrencyAllow:
+		if task.MaxConcurrency <= 0 {
+			return nil, m.checkRunnable(ctx, task.ID)
+		}
+		lock, err = m.repo.TryLockSlot(ctx, task.ID, owner, task.MaxConcurrency, m.leaseTTL)
+	case ConcurrencyReplace:
+		lock, err = m.repo.TryLockTask(ctx, task.ID, owner, m.leaseTTL)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := m.checkRunnable(ctx, task.ID); err != nil {
+				return nil, err
+			}
+			return m.replaceRunning(ctx, task, owner, lg)
+		}
+		return lock, err
+	default:
+		lock, err = m.repo.TryLockTask(ctx, task.ID, owner, m.leaseTTL)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := m.checkRunnable(ctx, task.ID); err != nil {
+			return nil, err
+		}
+	}
+	return lock, err
+}
+
+// checkRunnable 检查任务当前是否可以执行，调度器中的任务配置可能尚未同步暂停或停用
+func (m *TaskManager) checkRunnable(ctx context.Context, taskID int64) error {
+	task, err := m.repo.GetTask(ctx, taskID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !task.Enabled {
+		return ErrTaskNotFound
+	}
+	if task.Paused {
+		return ErrTaskPaused
+	}
+	return nil
+}
+
+// replaceRunning 请求取消持有任务锁的执行并等待其释放锁，超过取消检查间隔加宽限时间仍未释放则强制接管
+func (m *TaskManager) replaceRunning(ctx context.Context, task *model.Hawthorn_task, owner string, lg *zap.Logger) (*taskLock, error) {
+	holder, err := m.repo.GetLockHolder(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if holder == "" {
+		return m.repo.TryLockTask(ctx, task.ID, owner, m.leaseTTL)
+	}
+	traceID := holder[strings.LastIndex(holder, ":")+1:]
+	lg.Info("取消执行中的任务后重新执行", zap.Int64("taskID", task.ID), zap.String("lockedBy", holder))
+	if err := m.Cancel(context.WithValue(ctx, "user_id", operatorSystem), traceID, replacedReason); err != nil {
+		lg.Warn("请求取消执行中的任务失败", zap.String("traceID", traceID), zap.Error(err))
+	}
+
+	deadline := time.Now().Add(m.pollInterval + m.timeoutGrace)
+	for time.Now().Before(deadline) {
+		if err := sleepContext(m.ctx, replaceCheckInterval); err != nil {
+			return nil, err
+		}
+		lock, err := m.repo.TryLockTask(ctx, task.ID, owner, m.leaseTTL)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return lock, err
+		}
+	}
+	lg.Warn("原执行未及时释放任务锁，强制接管", zap.Int64("taskID", task.ID), zap.String("lockedBy", holder))
+	return m.repo.TakeOverLockTask(ctx, task.ID, holder, owner, m.leaseTTL)
+}
@@ -0,0 +1,200 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	DependOnSuccess = "success" // 上游成功
+	DependOnFailure = "failure" // 上游失败、超时或取消
+	DependOnAny     = "any"     // 上游执行结束即可
+)
+
+var ErrDependencyCycle = errors.New("任务依赖存在环")
+
+// finishedStates 可以触发下游依赖的执行状态
+var finishedStates = []string{stateSuccess, stateFiled, stateTimeout, stateCancelled}
+
+// dependencyState 下游任务的一个上游依赖，及上游在该计划时间最近一次结束的执行状态
+type dependencyState struct {
+	UpstreamID int64
+	Condition  string
+	Status     *string
+}
+
+func (d dependencyState) satisfied() bool {
+	if d.Status == nil {
+		return false
+	}
+	switch d.Condition {
+	case DependOnAny:
+		return true
+	case DependOnFailure:
+		return *d.Status != stateSuccess
+	}
+	return *d.Status == stateSuccess
+}
+
+func isFinished(status string) bool {
+	for _, s := range finishedStates {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerDownstream 上游执行结束后，为全部依赖都已满足的下游任务登记触发。
+// 多个上游同时结束时可能重复登记，下游执行时按计划时间抢占，只会执行一次。
+func (m *TaskManager) triggerDownstream(ctx context.Context, task *model.Hawthorn_task, execution *model.Hawthorn_task_execution, lg *zap.Logger) {
+	if execution.ScheduledAt == nil || !isFinished(execution.Status) {
+		return
+	}
+	downstreamIDs, err := m.repo.GetDownstreamIDs(ctx, task.ID)
+	if err != nil {
+		lg.Error("查询下游依赖失败", zap.Error(err))
+		return
+	}
+	for _, id := range downstreamIDs {
+		states, err := m.repo.GetDependencyStates(ctx, id, *execution.ScheduledAt)
+		if err != nil {
+			lg.Error("查询下游依赖状态失败", zap.Int64("taskID", id), zap.Error(err))
+			continue
+		}
+		ready := len(states) > 0
+		for _, state := range states {
+			ready = ready && state.satisfied()
+		}
+		if !ready {
+			continue
+		}
+		trigger := &model.Hawthorn_task_trigger{
+			TaskID:      id,
+			TriggerType: TriggerDependency,
+			TriggerBy:   task.Name,
+			ScheduledAt: execution.ScheduledAt,
+			Status:      triggerPending,
+		}
+		if err := m.repo.CreateTrigger(ctx, trigger); err != nil {
+			lg.Error("登记下游任务触发失败", zap.Int64("taskID", id), zap.Error(err))
+			continue
+		}
+		lg.Info("上游依赖已满足，触发下游任务", zap.Int64("taskID", id), zap.Time("scheduledAt", *execution.ScheduledAt))
+	}
+}
+
+// SaveDependencies 替换任务的全部上游依赖，保存前检查是否成环
+func (m *TaskManager) SaveDependencies(ctx context.Context, taskID int64, upstreams []*model.Hawthorn_task_dependency) error {
+	if _, err := m.repo.GetTask(ctx, taskID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	seen := make(map[int64]bool)
+	for _, dep := range upstreams {
+		if dep.Condition == "" {
+			dep.Condition = DependOnSuccess
+		}
+		if dep.Condition != DependOnSuccess && dep.Condition != DependOnFailure && dep.Condition != DependOnAny {
+			return fmt.Errorf("依赖条件[%s]错误", dep.Condition)
+		}
+		if seen[dep.UpstreamID] {
+			return fmt.Errorf("上游任务[%d]重复", dep.UpstreamID)
+		}
+		seen[dep.UpstreamID] = true
+		if _, err := m.repo.GetTask(ctx, dep.UpstreamID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("上游任务[%d]不存在", dep.UpstreamID)
+			}
+			return err
+		}
+		dep.ID = 0
+		dep.TaskID = taskID
+	}
+
+	deps, err := m.repo.GetDependencies(ctx)
+	if err != nil {
+		return err
+	}
+	kept := deps[:0]
+	for _, dep := range deps {
+		if dep.TaskID != taskID {
+			kept = append(kept, dep)
+		}
+	}
+	if hasDependencyCycle(append(kept, upstreams...), taskID) {
+		return ErrDependencyCycle
+	}
+
+	operator := contextString(ctx, "user_id")
+	op := &model.Hawthorn_task_operation{
+		TaskID:   taskID,
+		Action:   ActionSaveDependency,
+		Operator: operator,
+		Detail:   fmt.Sprintf("%v", upstreamIDs(upstreams)),
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	if err := m.repo.SaveDependencies(ctx, taskID, upstreams, op); err != nil {
+		return err
+	}
+	m.logger.Infof("%s设置任务[%v]的上游依赖为%v", operator, taskID, op.Detail)
+	return nil
+}
+
+func upstreamIDs(deps []*model.Hawthorn_task_dependency) []int64 {
+	ids := make([]int64, 0, len(deps))
+	for _, dep := range deps {
+		ids = append(ids, dep.UpstreamID)
+	}
+	return ids
+}
+
+// hasDependencyCycle 判断依赖图中是否存在经过taskID的环
+func hasDependencyCycle(deps []*model.Hawthorn_task_dependency, taskID int64) bool {
+	downstream := make(map[int64][]int64)
+	for _, dep := range deps {
+		downstream[dep.UpstreamID] = append(downstream[dep.UpstreamID], dep.TaskID)
+	}
+	visited := make(map[int64]bool)
+	stack := append([]int64(nil), downstream[taskID]...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == taskID {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		stack = append(stack, downstream[id]...)
+	}
+	return false
+}
+
+// DagTaskIDs 与taskID直接或间接存在依赖关系的全部任务，包含taskID本身
+func DagTaskIDs(deps []*model.Hawthorn_task_dependency, taskID int64) []int64 {
+	adjacent := make(map[int64][]int64)
+	for _, dep := range deps {
+		adjacent[dep.UpstreamID] = append(adjacent[dep.UpstreamID], dep.TaskID)
+		adjacent[dep.TaskID] = append(adjacent[dep.TaskID], dep.UpstreamID)
+	}
+	visited := map[int64]bool{taskID: true}
+	ids := []int64{taskID}
+	for i := 0; i < len(ids); i++ {
+		for _, id := range adjacent[ids[i]] {
+			if !visited[id] {
+				visited[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
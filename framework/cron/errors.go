@@ -0,0 +1,71 @@
+package cron
+
+import (
+	"errors"
+	"time"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误不可重试，任务直接记为失败
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string { return "任务跳过：" + e.reason }
+
+// Skip 放弃本次执行，执行记录状态为skipped
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
+
+type retryAfterError struct {
+	delay time.Duration
+	err   error
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter 按指定间隔重试，替代任务配置的重试策略计算出的间隔
+func RetryAfter(d time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{delay: d, err: err}
+}
+
+// IsPermanent 错误是否不可重试，参数校验失败同样不重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe) || errors.Is(err, ErrInvalidParams)
+}
+
+// SkipReason 返回Skip设置的原因
+func SkipReason(err error) (string, bool) {
+	var se *skipError
+	if errors.As(err, &se) {
+		return se.reason, true
+	}
+	return "", false
+}
+
+func retryDelay(err error) (time.Duration, bool) {
+	var re *retryAfterError
+	if errors.As(err, &re) {
+		return re.delay, true
+	}
+	return 0, false
+}
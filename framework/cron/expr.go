@@ -0,0 +1,321 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 扩展cron语法，兼容Quartz的L、W、#：
+//
+//	日字段：L 月末，L-n 月末前n天，LW 月末最后一个工作日，nW 离n号最近的工作日（不跨月，
+//	       n超过当月天数时按月末计算，如31W在小月取离30号最近的工作日）
+//	周字段：nL 当月最后一个周n，n#k 当月第k个周n，L 周六
+//
+// 周字段取值与robfig/cron一致，0为周日，也可使用SUN-SAT。
+// 不包含扩展符号的表达式仍交给robfig/cron解析。
+
+const (
+	starBit = 1 << 63 // 与robfig/cron一致，字段为*或?
+	// maxScanDays 向后查找触发日期的最大天数，超过视为不会再触发
+	maxScanDays = 366 * 5
+)
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// dayRule 日字段或周字段中的扩展规则
+type dayRule func(day time.Time) bool
+
+// extSchedule 支持扩展语法的调度计划，时、分、秒、月沿用robfig/cron的位图
+type extSchedule struct {
+	spec     cron.SpecSchedule
+	domRules []dayRule
+	dowRules []dayRule
+}
+
+// parseCronExpr 解析cron表达式，loc不为空时覆盖表达式自身的时区
+func parseCronExpr(expr string, loc *time.Location) (cron.Schedule, error) {
+	fields, tz := splitCronExpr(expr)
+	if len(fields) != 6 || !isExtendedField(fields[3], false) && !isExtendedField(fields[5], true) {
+		sched, err := scheduleParser.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		if spec, ok := sched.(*cron.SpecSchedule); ok && loc != nil {
+			spec.Location = loc
+		}
+		if every, ok := sched.(cron.ConstantDelaySchedule); ok {
+			return alignedSchedule{delay: every.Delay}, nil
+		}
+		return sched, nil
+	}
+
+	if loc == nil {
+		loc = time.Local
+		if tz != "" {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("时区[%s]无效: %w", tz, err)
+			}
+			loc = l
+		}
+	}
+	return parseExtendedExpr(fields, loc)
+}
+
+// splitCronExpr 拆分表达式字段，并取出CRON_TZ=或TZ=前缀中的时区
+func splitCronExpr(expr string) (fields []string, tz string) {
+	fields = strings.Fields(expr)
+	if len(fields) > 0 {
+		for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+			if strings.HasPrefix(fields[0], prefix) {
+				return fields[1:], strings.TrimPrefix(fields[0], prefix)
+			}
+		}
+	}
+	return fields, ""
+}
+
+func isExtendedField(field string, dow bool) bool {
+	for _, item := range strings.Split(strings.ToUpper(field), ",") {
+		if dow {
+			if strings.Contains(item, "#") || strings.HasSuffix(item, "L") {
+				return true
+			}
+		} else if strings.ContainsAny(item, "LW") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseExtendedExpr(fields []string, loc *time.Location) (cron.Schedule, error) {
+	domItems, domRules, err := splitDayField(fields[3], parseDomRule)
+	if err != nil {
+		return nil, err
+	}
+	dowItems, dowRules, err := splitDayField(fields[5], parseDowRule)
+	if err != nil {
+		return nil, err
+	}
+
+	// 普通部分交给robfig/cron解析，只有扩展规则的字段位图置空
+	std := []string{fields[0], fields[1], fields[2], orStar(domItems), fields[4], orStar(dowItems)}
+	sched, err := scheduleParser.Parse(strings.Join(std, " "))
+	if err != nil {
+		return nil, err
+	}
+	spec := *sched.(*cron.SpecSchedule)
+	spec.Location = loc
+	if len(domItems) == 0 {
+		spec.Dom = 0
+	}
+	if len(dowItems) == 0 {
+		spec.Dow = 0
+	}
+	return &extSchedule{spec: spec, domRules: domRules, dowRules: dowRules}, nil
+}
+
+// splitDayField 将字段拆分为普通部分和扩展规则
+func splitDayField(field string, parse func(item string) (dayRule, bool, error)) ([]string, []dayRule, error) {
+	var items []string
+	var rules []dayRule
+	for _, item := range strings.Split(field, ",") {
+		rule, ok, err := parse(strings.ToUpper(item))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			rules = append(rules, rule)
+		} else {
+			items = append(items, item)
+		}
+	}
+	return items, rules, nil
+}
+
+func orStar(items []string) string {
+	if len(items) == 0 {
+		return "*"
+	}
+	return strings.Join(items, ",")
+}
+
+func parseDomRule(item string) (dayRule, bool, error) {
+	switch {
+	case item == "L":
+		return func(day time.Time) bool {
+			return day.Day() == lastDayOfMonth(day)
+		}, true, nil
+	case item == "LW":
+		return func(day time.Time) bool {
+			return day.Day() == lastWeekdayOfMonth(day)
+		}, true, nil
+	case strings.HasPrefix(item, "L-"):
+		n, err := parseRange(item[2:], 0, 30, item)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(day time.Time) bool {
+			return day.Day() == lastDayOfMonth(day)-n
+		}, true, nil
+	case strings.HasSuffix(item, "W"):
+		n, err := parseRange(strings.TrimSuffix(item, "W"), 1, 31, item)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(day time.Time) bool {
+			return day.Day() == nearestWeekday(day, n)
+		}, true, nil
+	}
+	return nil, false, nil
+}
+
+func parseDowRule(item string) (dayRule, bool, error) {
+	switch {
+	case item == "L":
+		return func(day time.Time) bool {
+			return day.Weekday() == time.Saturday
+		}, true, nil
+	case strings.Contains(item, "#"):
+		w, k, _ := strings.Cut(item, "#")
+		weekday, err := parseWeekday(w, item)
+		if err != nil {
+			return nil, false, err
+		}
+		nth, err := parseRange(k, 1, 5, item)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(day time.Time) bool {
+			return day.Weekday() == weekday && (day.Day()-1)/7+1 == nth
+		}, true, nil
+	case strings.HasSuffix(item, "L"):
+		weekday, err := parseWeekday(strings.TrimSuffix(item, "L"), item)
+		if err != nil {
+			return nil, false, err
+		}
+		return func(day time.Time) bool {
+			return day.Weekday() == weekday && day.Day()+7 > lastDayOfMonth(day)
+		}, true, nil
+	}
+	return nil, false, nil
+}
+
+func parseRange(s string, min, max int, item string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("cron表达式[%s]无效，取值范围%d-%d", item, min, max)
+	}
+	return n, nil
+}
+
+func parseWeekday(s string, item string) (time.Weekday, error) {
+	if n, ok := weekdayNames[strings.ToLower(s)]; ok {
+		return time.Weekday(n), nil
+	}
+	n, err := parseRange(s, 0, 6, item)
+	return time.Weekday(n), err
+}
+
+func lastDayOfMonth(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// lastWeekdayOfMonth 当月最后一个工作日（周一至周五）
+func lastWeekdayOfMonth(day time.Time) int {
+	last := lastDayOfMonth(day)
+	switch time.Date(day.Year(), day.Month(), last, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		return last - 1
+	case time.Sunday:
+		return last - 2
+	}
+	return last
+}
+
+// nearestWeekday 离当月n号最近的工作日，不跨月；n超过当月天数时按月末计算
+func nearestWeekday(day time.Time, n int) int {
+	last := lastDayOfMonth(day)
+	if n > last {
+		n = last
+	}
+	switch time.Date(day.Year(), day.Month(), n, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if n == 1 {
+			return n + 2
+		}
+		return n - 1
+	case time.Sunday:
+		if n == last {
+			return n - 2
+		}
+		return n + 1
+	}
+	return n
+}
+
+func (s *extSchedule) dayMatches(day time.Time) bool {
+	domMatch := s.spec.Dom&(1<<uint(day.Day())) > 0
+	for _, rule := range s.domRules {
+		domMatch = domMatch || rule(day)
+	}
+	dowMatch := s.spec.Dow&(1<<uint(day.Weekday())) > 0
+	for _, rule := range s.dowRules {
+		dowMatch = dowMatch || rule(day)
+	}
+	// 与robfig/cron一致：任一字段为*时取交集，否则取并集
+	if s.spec.Dom&starBit > 0 || s.spec.Dow&starBit > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next 返回t之后的第一个触发时间，找不到时返回零值
+func (s *extSchedule) Next(t time.Time) time.Time {
+	loc := s.spec.Location
+	t = t.In(loc)
+	after := t.Add(time.Second - time.Duration(t.Nanosecond()))
+	for i := 0; i < maxScanDays; i++ {
+		day := time.Date(after.Year(), after.Month(), after.Day()+i, 0, 0, 0, 0, loc)
+		if s.spec.Month&(1<<uint(day.Month())) == 0 || !s.dayMatches(day) {
+			continue
+		}
+		if next, ok := s.timeOnDay(day, after); ok {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// timeOnDay 当天不早于after的第一个触发时间，跳过夏令时切换中不存在的时间
+func (s *extSchedule) timeOnDay(day, after time.Time) (time.Time, bool) {
+	y, mon, d := day.Date()
+	loc := day.Location()
+	for h := 0; h < 24; h++ {
+		if s.spec.Hour&(1<<uint(h)) == 0 || time.Date(y, mon, d, h, 59, 59, 0, loc).Before(after) {
+			continue
+		}
+		for mi := 0; mi < 60; mi++ {
+			if s.spec.Minute&(1<<uint(mi)) == 0 || time.Date(y, mon, d, h, mi, 59, 0, loc).Before(after) {
+				continue
+			}
+			for sec := 0; sec < 60; sec++ {
+				if s.spec.Second&(1<<uint(sec)) == 0 {
+					continue
+				}
+				next := time.Date(y, mon, d, h, mi, sec, 0, loc)
+				if next.Before(after) || next.Hour() != h || next.Day() != d {
+					continue
+				}
+				return next, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
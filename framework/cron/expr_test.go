@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronExprExtended(t *testing.T) {
+	date := func(y int, m time.Month, d, h, mi int) time.Time {
+		return time.Date(y, m, d, h, mi, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"L闰年二月", "0 0 0 L * ?", date(2028, 2, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"L平年二月", "0 0 0 L * ?", date(2027, 2, 1, 0, 0), date(2027, 2, 28, 0, 0)},
+		{"L跨月", "0 0 0 L * ?", date(2026, 1, 31, 0, 0), date(2026, 2, 28, 0, 0)},
+		{"L跨年", "0 0 0 L * ?", date(2026, 12, 31, 0, 0), date(2027, 1, 31, 0, 0)},
+		{"L当天未到时刻", "0 30 9 L * ?", date(2026, 4, 30, 9, 0), date(2026, 4, 30, 9, 30)},
+		{"L-n闰年二月", "0 0 0 L-3 * ?", date(2028, 2, 1, 0, 0), date(2028, 2, 26, 0, 0)},
+		{"L-n平年二月", "0 0 0 L-3 * ?", date(2027, 2, 1, 0, 0), date(2027, 2, 25, 0, 0)},
+		{"L-n大于当月天数的月份跳过", "0 0 0 L-30 * ?", date(2026, 2, 1, 0, 0), date(2026, 3, 1, 0, 0)},
+		{"LW月末为周六", "0 0 0 LW * ?", date(2026, 2, 1, 0, 0), date(2026, 2, 27, 0, 0)},
+		{"LW月末为周日", "0 0 0 LW * ?", date(2026, 5, 1, 0, 0), date(2026, 5, 29, 0, 0)},
+		{"LW月末为工作日", "0 0 0 LW * ?", date(2026, 3, 1, 0, 0), date(2026, 3, 31, 0, 0)},
+		{"1W一号为周六不跨到上月", "0 0 0 1W * ?", date(2026, 7, 31, 0, 0), date(2026, 8, 3, 0, 0)},
+		{"15W为周六取周五", "0 0 0 15W * ?", date(2026, 8, 1, 0, 0), date(2026, 8, 14, 0, 0)},
+		{"15W为周日取周一", "0 0 0 15W * ?", date(2026, 2, 1, 0, 0), date(2026, 2, 16, 0, 0)},
+		{"31W小月按月末", "0 0 0 31W * ?", date(2026, 4, 1, 0, 0), date(2026, 4, 30, 0, 0)},
+		{"31W小月月末为周日不跨月", "0 0 0 31W * ?", date(2025, 11, 1, 0, 0), date(2025, 11, 28, 0, 0)},
+		{"31W闰年二月", "0 0 0 31W 2 ?", date(2028, 1, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"n#k第一个周一", "0 0 0 ? * 1#1", date(2026, 3, 1, 0, 0), date(2026, 3, 2, 0, 0)},
+		{"n#k第五个周五跳过没有的月份", "0 0 0 ? * 5#5", date(2026, 2, 1, 0, 0), date(2026, 5, 29, 0, 0)},
+		{"n#k星期名称", "0 0 0 ? * MON#2", date(2026, 3, 1, 0, 0), date(2026, 3, 9, 0, 0)},
+		{"nL最后一个周五", "0 0 0 ? * 5L", date(2026, 2, 1, 0, 0), date(2026, 2, 27, 0, 0)},
+		{"nL最后一个周日", "0 0 0 ? * 0L", date(2026, 3, 1, 0, 0), date(2026, 3, 29, 0, 0)},
+		{"nL闰年二月", "0 0 0 ? * 2L", date(2028, 2, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"周字段L为周六", "0 0 0 ? * L", date(2026, 3, 1, 0, 0), date(2026, 3, 7, 0, 0)},
+		{"扩展与普通日期取并集", "0 0 0 10,L * 1", date(2026, 4, 11, 0, 0), date(2026, 4, 13, 0, 0)},
+		{"指定月份", "0 0 0 L 2 ?", date(2026, 3, 1, 0, 0), date(2027, 2, 28, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseCronExpr(tt.expr, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("%s.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronExprInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"0 0 0 32W * ?",
+		"0 0 0 0W * ?",
+		"0 0 0 L-31 * ?",
+		"0 0 0 ? * 1#6",
+		"0 0 0 ? * 7#1",
+		"0 0 0 ? * XL",
+	} {
+		if _, err := parseCronExpr(expr, time.UTC); err == nil {
+			t.Errorf("%s: want error", expr)
+		}
+	}
+}
+
+func TestExtendedScheduleDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// 2026-03-08为三月第二个周日，02:00-03:00不存在
+		{"跳过不存在的时间", "0 30 2,4 ? 3 0#2", time.Date(2026, 3, 1, 0, 0, 0, 0, loc), time.Date(2026, 3, 8, 4, 30, 0, 0, loc)},
+		{"不存在时刻之前正常触发", "0 30 1,2 ? 3 0#2", time.Date(2026, 3, 1, 0, 0, 0, 0, loc), time.Date(2026, 3, 8, 1, 30, 0, 0, loc)},
+		{"不存在时刻不顺延到同一小时", "0 30 2 ? 3 0#2", time.Date(2026, 3, 8, 1, 30, 0, 0, loc), time.Time{}},
+		// 2026-11-01回拨，01:30出现两次，取第一次
+		{"重复的时间取第一次", "0 30 1 ? 11 0#1", time.Date(2026, 10, 31, 0, 0, 0, 0, loc), time.Date(2026, 11, 1, 1, 30, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseCronExpr(tt.expr, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("%s.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+type taskHandler struct {
+	name           string
+	fn             TaskFunc
+	validateParams func(params json.RawMessage) error
+}
+
+type TaskOption func(h *taskHandler)
+
+// WithParamsValidator 执行前校验任务参数，校验失败时不调用任务函数
+func WithParamsValidator(fn func(params json.RawMessage) error) TaskOption {
+	return func(h *taskHandler) {
+		h.validateParams = fn
+	}
+}
+
+// WithParamsType 执行前按示例类型绑定并校验任务参数
+func WithParamsType(sample interface{}) TaskOption {
+	typ := reflect.TypeOf(sample)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return WithParamsValidator(func(params json.RawMessage) error {
+		v := reflect.New(typ).Interface()
+		return TaskParams{Params: params}.Bind(v)
+	})
+}
+
+func (h *taskHandler) checkParams(params json.RawMessage) error {
+	if err := validParams(params); err != nil {
+		return err
+	}
+	if h.validateParams == nil {
+		return nil
+	}
+	return h.validateParams(params)
+}
+
+func funcName(fn TaskFunc) string {
+	pc := reflect.ValueOf(fn).Pointer()
+	return runtime.FuncForPC(pc).Name()
+}
+
+func (m *TaskManager) RegisterTask(fn TaskFunc, opts ...TaskOption) error {
+	return m.RegisterTaskNamed(funcName(fn), fn, opts...)
+}
+
+// RegisterTaskNamed 以指定名称注册任务函数，hawthorn_task.handler 通过该名称引用
+func (m *TaskManager) RegisterTaskNamed(name string, fn TaskFunc, opts ...TaskOption) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("任务名称不能为空")
+	}
+	if fn == nil {
+		return fmt.Errorf("任务 [%s] 函数不能为空", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.taskFuncs[name]; exists {
+		return fmt.Errorf("任务 [%s] 已注册", name)
+	}
+
+	h := &taskHandler{name: name, fn: fn}
+	for _, opt := range opts {
+		opt(h)
+	}
+	m.taskFuncs[name] = h
+	m.logger.Debugf("注册任务成功:%s", name)
+	return nil
+}
+
+func (m *TaskManager) getHandler(name string) (*taskHandler, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, exists := m.taskFuncs[name]
+	return h, exists
+}
@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+const (
+	JitterRandom = "random" // 每次触发在窗口内随机延迟
+	JitterFixed  = "fixed"  // 按任务名哈希得到固定延迟
+)
+
+// taskHash 按任务名计算的稳定哈希，salt区分不同用途
+func taskHash(name string, salt string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(salt))
+	return h.Sum32()
+}
+
+// jitterDelay 本次触发前的延迟，计划触发时间仍为延迟前的时间
+func jitterDelay(task *model.Hawthorn_task) time.Duration {
+	window := time.Duration(task.JitterWindow) * time.Second
+	if window <= 0 {
+		return 0
+	}
+	switch task.JitterMode {
+	case JitterRandom:
+		return rand.N(window)
+	case JitterFixed:
+		return time.Duration(taskHash(task.Name, "jitter")%uint32(window/time.Millisecond)) * time.Millisecond
+	}
+	return 0
+}
+
+// hashFieldRange H可取值的范围，日字段取1-28以保证每月都能触发
+var hashFieldRange = [6][2]int{{0, 59}, {0, 59}, {0, 23}, {1, 28}, {1, 12}, {0, 6}}
+
+// expandHashTokens 将表达式中的H替换为按任务名哈希得到的固定值，支持H、H(a-b)、H/n、H(a-b)/n
+func expandHashTokens(expr string, seed string) (string, error) {
+	if !strings.Contains(expr, "H") || strings.HasPrefix(strings.TrimSpace(expr), "@") {
+		return expr, nil
+	}
+	fields, tz := splitCronExpr(expr)
+	if len(fields) != len(hashFieldRange) {
+		return expr, nil
+	}
+	for i, field := range fields {
+		items := strings.Split(field, ",")
+		for j, item := range items {
+			if item != "H" && !strings.HasPrefix(item, "H(") && !strings.HasPrefix(item, "H/") {
+				continue
+			}
+			expanded, err := expandHashItem(item, hashFieldRange[i][0], hashFieldRange[i][1], taskHash(seed, strconv.Itoa(i)))
+			if err != nil {
+				return "", err
+			}
+			items[j] = expanded
+		}
+		fields[i] = strings.Join(items, ",")
+	}
+	if tz != "" {
+		return "CRON_TZ=" + tz + " " + strings.Join(fields, " "), nil
+	}
+	return strings.Join(fields, " "), nil
+}
+
+func expandHashItem(item string, lo, hi int, hash uint32) (string, error) {
+	rest := item[1:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("cron表达式[%s]无效", item)
+		}
+		a, b, ok := strings.Cut(rest[1:end], "-")
+		from, err1 := strconv.Atoi(a)
+		to, err2 := strconv.Atoi(b)
+		if !ok || err1 != nil || err2 != nil || from < lo || to > hi || from > to {
+			return "", fmt.Errorf("cron表达式[%s]无效，取值范围%d-%d", item, lo, hi)
+		}
+		lo, hi, rest = from, to, rest[end+1:]
+	}
+	if rest == "" {
+		return strconv.Itoa(lo + int(hash%uint32(hi-lo+1))), nil
+	}
+	if !strings.HasPrefix(rest, "/") {
+		return "", fmt.Errorf("cron表达式[%s]无效", item)
+	}
+	step, err := strconv.Atoi(rest[1:])
+	if err != nil || step <= 0 {
+		return "", fmt.Errorf("cron表达式[%s]步长无效", item)
+	}
+	offset := int(hash % uint32(min(step, hi-lo+1)))
+	return fmt.Sprintf("%d-%d/%d", lo+offset, hi, step), nil
+}
@@ -0,0 +1,59 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var errLockLost = errors.New("任务锁已丢失")
+
+// keepLease 任务执行期间定期续期任务锁，锁被他人持有或续期持续失败超过租约时长时取消任务。
+// 返回的函数停止续期并等待续期协程退出。
+func (m *TaskManager) keepLease(ctx context.Context, cancel context.CancelCauseFunc, lock *taskLock, lg *zap.Logger) func() {
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(m.renewInterval)
+		defer ticker.Stop()
+		lastRenew := time.Now()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := m.repo.RenewLockTask(context.WithoutCancel(ctx), lock, m.leaseTTL)
+			if err == nil {
+				lastRenew = time.Now()
+				continue
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				lg.Error("任务锁已被其他节点持有或被强制释放，取消任务", zap.Int64("taskID", lock.TaskID))
+				cancel(errLockLost)
+				return
+			}
+			lg.Warn("任务锁续期失败", zap.Int64("taskID", lock.TaskID), zap.Error(err))
+			if time.Since(lastRenew) >= m.leaseTTL {
+				lg.Error("任务锁续期持续失败，租约已过期，取消任务", zap.Int64("taskID", lock.TaskID))
+				cancel(errLockLost)
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopCh)
+			wg.Wait()
+		})
+	}
+}
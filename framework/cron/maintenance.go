@@ -0,0 +1,174 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+const (
+	clusterSuspended  = "集群调度已暂停"
+	inMaintenance     = "处于维护窗口"
+	clusterControlRow = 1
+)
+
+// maintenanceWindow 解析后的维护窗口
+type maintenanceWindow struct {
+	name     string
+	group    string
+	start    int // 自0点起的分钟数
+	end      int
+	weekdays uint8 // 按位表示周日到周六，0表示每天
+	loc      *time.Location
+}
+
+func newMaintenanceWindow(w *model.Hawthorn_maintenance_window) (*maintenanceWindow, error) {
+	start, err := parseClock(w.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(w.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	mw := &maintenanceWindow{
+		name:  w.Name,
+		group: w.TaskGroup,
+		start: start,
+		end:   end,
+		loc:   time.Local,
+	}
+	if w.Timezone != "" {
+		if mw.loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return nil, fmt.Errorf("时区[%s]错误: %w", w.Timezone, err)
+		}
+	}
+	if mw.weekdays, err = parseWeekdays(w.Weekdays); err != nil {
+		return nil, err
+	}
+	return mw, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("时间[%s]格式错误，应为HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *maintenanceWindow) onDay(day time.Weekday) bool {
+	return w.weekdays == 0 || w.weekdays&(1<<day) != 0
+}
+
+// contains 判断时间t是否处于维护窗口，跨天窗口的星期按开始当天计算
+func (w *maintenanceWindow) contains(group string, t time.Time) bool {
+	if w.group != "" && w.group != group {
+		return false
+	}
+	t = t.In(w.loc)
+	minute := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end && w.onDay(t.Weekday())
+	}
+	if minute >= w.start {
+		return w.onDay(t.Weekday())
+	}
+	if minute < w.end {
+		return w.onDay((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+// loadMaintenanceWindows 同步维护窗口配置
+func (m *TaskManager) loadMaintenanceWindows() {
+	windows, err := m.repo.GetMaintenanceWindows(m.ctx)
+	if err != nil {
+		m.logger.Errorf("%v", err)
+		return
+	}
+	parsed := make([]*maintenanceWindow, 0, len(windows))
+	for _, w := range windows {
+		mw, err := newMaintenanceWindow(w)
+		if err != nil {
+			m.logger.Errorf("维护窗口[%v-%v]配置错误:%v", w.ID, w.Name, err)
+			continue
+		}
+		parsed = append(parsed, mw)
+	}
+	m.mu.Lock()
+	m.windows = parsed
+	m.mu.Unlock()
+}
+
+// inMaintenance 返回时间t命中的维护窗口名称
+func (m *TaskManager) inMaintenance(group string, t time.Time) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, w := range m.windows {
+		if w.contains(group, t) {
+			return w.name, true
+		}
+	}
+	return "", false
+}
+
+// skipReason 集群调度暂停或处于维护窗口时返回跳过原因，手动触发不受维护窗口限制
+func (m *TaskManager) skipReason(ctx context.Context, task *model.Hawthorn_task, fire taskFire) string {
+	control, err := m.repo.GetClusterControl(ctx)
+	if err != nil {
+		m.logger.Errorf("%v", err)
+	} else if control.Suspended {
+		return strings.TrimSpace(clusterSuspended + " " + control.Reason)
+	}
+	if fire.triggerType == TriggerManual {
+		return ""
+	}
+	if name, ok := m.inMaintenance(task.TaskGroup, fire.scheduledAt); ok {
+		return inMaintenance + ":" + name
+	}
+	return ""
+}
+
+// SuspendCluster 暂停集群内全部任务的调度，各节点触发时实时检查
+func (m *TaskManager) SuspendCluster(ctx context.Context, reason string) error {
+	return m.setClusterSuspended(ctx, true, reason)
+}
+
+// ResumeCluster 恢复集群调度
+func (m *TaskManager) ResumeCluster(ctx context.Context) error {
+	return m.setClusterSuspended(ctx, false, "")
+}
+
+func (m *TaskManager) setClusterSuspended(ctx context.Context, suspended bool, reason string) error {
+	operator := contextString(ctx, "user_id")
+	control := &model.Hawthorn_cluster_control{
+		ID:        clusterControlRow,
+		Suspended: suspended,
+		Reason:    reason,
+		UpdatedBy: operator,
+	}
+	action := ActionResumeCluster
+	if suspended {
+		action = ActionSuspendCluster
+	}
+	op := &model.Hawthorn_task_operation{
+		Action:   action,
+		Operator: operator,
+		Detail:   reason,
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	if err := m.repo.SaveClusterControl(ctx, control, op); err != nil {
+		return err
+	}
+	m.logger.Warnf("%s设置集群调度暂停状态为%v:%s", operator, suspended, reason)
+	return nil
+}
+
+// GetClusterControl 查询集群调度开关
+func (m *TaskManager) GetClusterControl(ctx context.Context) (*model.Hawthorn_cluster_control, error) {
+	return m.repo.GetClusterControl(ctx)
+}
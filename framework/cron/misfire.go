@@ -0,0 +1,78 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	MisfireIgnore   = "ignore"
+	MisfireFireOnce = "fire_once"
+	MisfireFireAll  = "fire_all"
+)
+
+// 计算错过时间点时的最大遍历次数，避免秒级任务长时间停机后遍历过多
+const maxMisfireScan = 100000
+
+// missedTimes 计算last之后、now之前错过的计划触发时间，按时间先后返回
+func missedTimes(task *model.Hawthorn_task, sched cron.Schedule, last time.Time, now time.Time) []time.Time {
+	limit := 0
+	switch task.MisfirePolicy {
+	case MisfireFireOnce:
+		limit = 1
+	case MisfireFireAll:
+		limit = task.MisfireLimit
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	var missed []time.Time
+	t := last
+	for i := 0; i < maxMisfireScan; i++ {
+		t = sched.Next(t)
+		if t.IsZero() || t.After(now) {
+			break
+		}
+		missed = append(missed, t)
+		if len(missed) > limit {
+			missed = missed[1:]
+		}
+	}
+	return missed
+}
+
+// checkMisfire 任务加入调度时检查停机期间错过的触发，按策略补偿执行
+func (m *TaskManager) checkMisfire(task *model.Hawthorn_task, sched cron.Schedule) {
+	if task.MisfirePolicy == "" || task.MisfirePolicy == MisfireIgnore {
+		return
+	}
+	last, err := m.repo.GetLastSuccessTime(m.ctx, task.ID)
+	if err != nil {
+		m.logger.Errorf("检查任务[%v-%v]错过的触发失败:%v", task.ID, task.Name, err)
+		return
+	}
+	if last == nil {
+		return
+	}
+	missed := missedTimes(task, sched, *last, time.Now())
+	if len(missed) == 0 {
+		return
+	}
+	m.logger.Infof("任务[%v-%v]错过%d次触发，按%s策略补偿", task.ID, task.Name, len(missed), task.MisfirePolicy)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				m.logger.Errorf("任务补偿执行异常:%v", err)
+			}
+		}()
+		for _, scheduledAt := range missed {
+			if m.ctx.Err() != nil {
+				return
+			}
+			m.executeTask(task, taskFire{scheduledAt: scheduledAt, triggerType: TriggerMisfire})
+		}
+	}()
+}
@@ -0,0 +1,72 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+func TestMissedTimes(t *testing.T) {
+	sched, err := scheduleParser.Parse("0 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	now := time.Date(2026, 3, 1, 13, 30, 0, 0, time.Local)
+	hour := func(h int) time.Time {
+		return time.Date(2026, 3, 1, h, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name   string
+		policy string
+		limit  int
+		last   time.Time
+		want   []time.Time
+	}{
+		{"ignore", MisfireIgnore, 10, last, nil},
+		{"fire_once取最近一次", MisfireFireOnce, 0, last, []time.Time{hour(13)}},
+		{"fire_all全部补偿", MisfireFireAll, 10, last, []time.Time{hour(10), hour(11), hour(12), hour(13)}},
+		{"fire_all超过上限保留最近", MisfireFireAll, 2, last, []time.Time{hour(12), hour(13)}},
+		{"没有错过", MisfireFireAll, 10, hour(13), nil},
+		{"last在now之后", MisfireFireAll, 10, hour(14), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.Hawthorn_task{MisfirePolicy: tt.policy, MisfireLimit: tt.limit}
+			got := missedTimes(task, sched, tt.last, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// 数据库读出的时间被标记为UTC，未按本地时钟重建时在非UTC时区会错过或重复补偿
+func TestMissedTimesFromDatabase(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	defer func() { time.Local = local }()
+
+	sched, err := scheduleParser.Parse("0 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Hawthorn_task{MisfirePolicy: MisfireFireAll, MisfireLimit: 100}
+	stored := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC) // 本地09:00写入，读出为UTC 09:00
+	now := time.Date(2026, 3, 1, 11, 30, 0, 0, time.Local)
+
+	got := missedTimes(task, sched, localClock(stored), now)
+	want := []time.Time{
+		time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local),
+		time.Date(2026, 3, 1, 11, 0, 0, 0, time.Local),
+	}
+	if len(got) != len(want) || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
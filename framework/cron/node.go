@@ -0,0 +1,67 @@
+package cron
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hawthorntrees/cronframework/framework/config"
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"github.com/hawthorntrees/cronframework/framework/utils"
+)
+
+// nodeAliveIntervals 超过该数量的同步周期未心跳的节点视为失联
+const nodeAliveIntervals = 3
+
+// heartbeat 登记本节点信息并刷新心跳时间，同时清理心跳超时的节点
+func (m *TaskManager) heartbeat() {
+	ip, err := utils.GetLocalIP()
+	if err != nil {
+		m.logger.Warnf("获取本机IP失败:%v", err)
+	}
+	handlers := m.handlerNames()
+	sort.Strings(handlers)
+	node := &model.Hawthorn_node{
+		NodeID:    m.nodeID,
+		IP:        ip,
+		AppName:   config.GetAppName(),
+		Version:   config.GetAppVersion(),
+		Handlers:  handlers,
+		StartTime: m.startTime,
+	}
+	if err := m.repo.SaveNode(m.ctx, node); err != nil {
+		m.logger.Errorf("节点心跳失败:%v", err)
+	}
+	rows, err := m.repo.DeleteStaleNodes(m.ctx, m.nodeExpiry)
+	if err != nil {
+		m.logger.Errorf("清理失联节点失败:%v", err)
+	} else if rows > 0 {
+		m.logger.Infof("清理心跳超时的节点%d个", rows)
+	}
+}
+
+// checkNodeID 节点ID已被其他存活节点使用时告警。节点ID用于清理重启前遗留的执行记录，共用时会误处理其他节点的执行
+func (m *TaskManager) checkNodeID() {
+	ip, _ := utils.GetLocalIP()
+	nodes, err := m.repo.GetNodes(m.ctx, nodeAliveIntervals*m.syncInterval)
+	if err != nil {
+		m.logger.Warnf("%v", err)
+		return
+	}
+	for _, node := range nodes {
+		if node.NodeID == m.nodeID && node.Alive && node.IP != ip {
+			m.logger.Errorf("节点ID[%s]已被存活节点%s使用，请为每个节点配置不同的node_id", m.nodeID, node.IP)
+		}
+	}
+}
+
+// unregisterNode 节点停止时从节点表移除
+func (m *TaskManager) unregisterNode() {
+	if err := m.repo.DeleteNode(context.Background(), m.nodeID); err != nil {
+		m.logger.Errorf("移除节点信息失败:%v", err)
+	}
+}
+
+// GetNodes 查询集群节点，最近心跳在若干个同步周期内的节点为存活
+func (m *TaskManager) GetNodes(ctx context.Context) ([]*NodeInfo, error) {
+	return m.repo.GetNodes(ctx, nodeAliveIntervals*m.syncInterval)
+}
@@ -0,0 +1,91 @@
+package cron
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrInvalidParams  = errors.New("任务参数校验失败")
+	ErrResultTooLarge = errors.New("任务结果过大")
+)
+
+// maxResultSize 任务结果序列化后的最大字节数
+const maxResultSize = 16 * 1024
+
+// ParamsValidator 参数绑定后的自校验，Bind 时自动调用
+type ParamsValidator interface {
+	Validate() error
+}
+
+// Bind 将任务参数解码到v中，v实现ParamsValidator时同时完成校验
+func (p TaskParams) Bind(v interface{}) error {
+	if len(p.Params) > 0 && !bytes.Equal(p.Params, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(p.Params))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+	if validator, ok := v.(ParamsValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+	return nil
+}
+
+func validParams(params json.RawMessage) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if !json.Valid(params) {
+		return fmt.Errorf("%w: 不是合法的JSON", ErrInvalidParams)
+	}
+	return nil
+}
+
+// taskResult 任务函数保存的结果，重试的各次尝试共用
+type taskResult struct {
+	mu   sync.Mutex
+	data json.RawMessage
+}
+
+func (r *taskResult) get() json.RawMessage {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.data
+}
+
+// SetResult 保存本次执行的结果，随执行记录入库并传给下游依赖任务，多次调用以最后一次为准
+func (p TaskParams) SetResult(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxResultSize {
+		return fmt.Errorf("%w: %d字节，上限%d字节", ErrResultTooLarge, len(data), maxResultSize)
+	}
+	if p.result == nil {
+		return nil
+	}
+	p.result.mu.Lock()
+	p.result.data = data
+	p.result.mu.Unlock()
+	return nil
+}
+
+// BindUpstream 将上游任务保存的结果解码到v中，上游未保存结果时返回false
+func (p TaskParams) BindUpstream(name string, v interface{}) (bool, error) {
+	data, ok := p.UpstreamResults[name]
+	if !ok || len(data) == 0 {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
@@ -0,0 +1,36 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+// Pause 暂停任务调度，resumeAt非空时到期后由同步任务自动恢复
+func (m *TaskManager) Pause(ctx context.Context, taskID int64, reason string, resumeAt *time.Time) error {
+	op := &model.Hawthorn_task_operation{
+		TaskID:   taskID,
+		Action:   ActionPause,
+		Operator: contextString(ctx, "user_id"),
+		Detail:   reason,
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	if resumeAt != nil {
+		// timestamp列按本地时钟保存
+		local := resumeAt.In(time.Local)
+		resumeAt = &local
+	}
+	return m.repo.PauseTask(ctx, op, resumeAt)
+}
+
+// Resume 恢复暂停的任务
+func (m *TaskManager) Resume(ctx context.Context, taskID int64) error {
+	op := &model.Hawthorn_task_operation{
+		TaskID:   taskID,
+		Action:   ActionResume,
+		Operator: contextString(ctx, "user_id"),
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	return m.repo.ResumeTask(ctx, op)
+}
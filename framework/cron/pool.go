@@ -0,0 +1,187 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errQueueTimeout = errors.New("节点执行数已满，排队超时")
+
+// WorkerStats 本节点执行池的运行情况
+type WorkerStats struct {
+	NodeID       string         `json:"node_id"`
+	MaxWorkers   int            `json:"max_workers"` // 0为不限制
+	Running      int            `json:"running"`
+	Queued       int            `json:"queued"`
+	GroupRunning map[string]int `json:"group_running"`
+	GroupLimits  map[string]int `json:"group_limits"`
+	Waited       int64          `json:"waited"`   // 曾排队的执行数
+	AvgWait      int64          `json:"avg_wait"` // 毫秒
+	MaxWait      int64          `json:"max_wait"` // 毫秒
+	Timeouts     int64          `json:"timeouts"` // 排队超时数
+}
+
+type poolWaiter struct {
+	group    string
+	priority int
+	seq      uint64
+	granted  bool
+	ready    chan struct{}
+}
+
+// workerPool 限制本节点同时执行的任务数，已满时按优先级排队，同优先级先到先得
+type workerPool struct {
+	mu           sync.Mutex
+	max          int
+	groupLimits  map[string]int
+	running      int
+	groupRunning map[string]int
+	waiters      []*poolWaiter
+	seq          uint64
+
+	waited    int64
+	totalWait time.Duration
+	maxWait   time.Duration
+	timeouts  int64
+}
+
+func newWorkerPool(limit int, groupLimits map[string]int) *workerPool {
+	return &workerPool{
+		max:          limit,
+		groupLimits:  groupLimits,
+		groupRunning: make(map[string]int),
+	}
+}
+
+func (p *workerPool) canRun(group string) bool {
+	if p.max > 0 && p.running >= p.max {
+		return false
+	}
+	limit, ok := p.groupLimits[group]
+	return !ok || limit <= 0 || p.groupRunning[group] < limit
+}
+
+// available 不考虑分组限制时还能立即执行的任务数，-1为不限制
+func (p *workerPool) available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.max <= 0 {
+		return -1
+	}
+	return max(p.max-p.running-len(p.waiters), 0)
+}
+
+// dispatch 按优先级依次放行可以执行的等待者
+func (p *workerPool) dispatch() {
+	sort.SliceStable(p.waiters, func(i, j int) bool {
+		if p.waiters[i].priority != p.waiters[j].priority {
+			return p.waiters[i].priority > p.waiters[j].priority
+		}
+		return p.waiters[i].seq < p.waiters[j].seq
+	})
+	remain := p.waiters[:0]
+	for _, w := range p.waiters {
+		if p.canRun(w.group) {
+			p.running++
+			p.groupRunning[w.group]++
+			w.granted = true
+			close(w.ready)
+			continue
+		}
+		remain = append(remain, w)
+	}
+	p.waiters = remain
+}
+
+// acquire 获取执行名额，超过timeout仍未轮到时返回errQueueTimeout。返回释放名额的函数及排队时长
+func (p *workerPool) acquire(ctx context.Context, group string, priority int, timeout time.Duration) (func(), time.Duration, error) {
+	start := time.Now()
+	p.mu.Lock()
+	p.seq++
+	w := &poolWaiter{group: group, priority: priority, seq: p.seq, ready: make(chan struct{})}
+	p.waiters = append(p.waiters, w)
+	p.dispatch()
+	granted := w.granted
+	p.mu.Unlock()
+
+	var err error
+	if !granted {
+		timer := time.NewTimer(timeout)
+		select {
+		case <-w.ready:
+		case <-timer.C:
+			err = errQueueTimeout
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+		}
+		timer.Stop()
+	}
+
+	waited := time.Since(start)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil && !w.granted {
+		for i, x := range p.waiters {
+			if x == w {
+				p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+				break
+			}
+		}
+		if errors.Is(err, errQueueTimeout) {
+			p.timeouts++
+		}
+		return nil, waited, err
+	}
+	if waited > time.Millisecond {
+		p.waited++
+		p.totalWait += waited
+		p.maxWait = max(p.maxWait, waited)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { p.release(group) })
+	}, waited, nil
+}
+
+func (p *workerPool) release(group string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	p.groupRunning[group]--
+	if p.groupRunning[group] <= 0 {
+		delete(p.groupRunning, group)
+	}
+	p.dispatch()
+}
+
+func (p *workerPool) stats() WorkerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := WorkerStats{
+		MaxWorkers:   p.max,
+		Running:      p.running,
+		Queued:       len(p.waiters),
+		GroupRunning: make(map[string]int, len(p.groupRunning)),
+		GroupLimits:  p.groupLimits,
+		Waited:       p.waited,
+		MaxWait:      p.maxWait.Milliseconds(),
+		Timeouts:     p.timeouts,
+	}
+	for group, n := range p.groupRunning {
+		stats.GroupRunning[group] = n
+	}
+	if p.waited > 0 {
+		stats.AvgWait = (p.totalWait / time.Duration(p.waited)).Milliseconds()
+	}
+	return stats
+}
+
+// WorkerStats 本节点执行池的运行情况
+func (m *TaskManager) WorkerStats() WorkerStats {
+	stats := m.pool.stats()
+	stats.NodeID = m.nodeID
+	return stats
+}
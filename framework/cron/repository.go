@@ -2,14 +2,56 @@ package cron
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hawthorntrees/cronframework/framework/dbs"
 	"github.com/hawthorntrees/cronframework/framework/model"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
+var (
+	ErrTaskNotLocked = errors.New("任务未被锁定")
+	ErrTaskNotPaused = errors.New("任务不存在或未暂停")
+)
+
+// 系统自动操作的操作人
+const operatorSystem = "system"
+
+const (
+	ActionForceUnlock    = "force_unlock"
+	ActionCancel         = "cancel"
+	ActionTrigger        = "trigger"
+	ActionPause          = "pause"
+	ActionResume         = "resume"
+	ActionSuspendCluster = "suspend_cluster"
+	ActionResumeCluster  = "resume_cluster"
+	ActionImportCalendar = "import_calendar"
+	ActionSaveDependency = "save_dependency"
+	ActionRetryShard     = "retry_shard"
+)
+
+// LockedTask 被锁定的任务及锁持有信息
+type LockedTask struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	LockedBy  *string    `json:"locked_by"`
+	LockedAt  *time.Time `json:"locked_at"`
+	ExpiredAt *time.Time `json:"expired_at"`
+	Expired   bool       `json:"expired"`
+	Slot      *int       `json:"slot"` // 允许并发的任务的执行槽位，为空时是任务行上的锁
+}
+
+// NodeInfo 集群节点，alive表示最近心跳未超时
+type NodeInfo struct {
+	model.Hawthorn_node
+	Alive bool `json:"alive"`
+}
+
 type Repository struct {
 	db func() *gorm.DB
 }
@@ -26,21 +68,118 @@ func (r *Repository) GetEnabledTasks(ctx context.Context) ([]*model.Hawthorn_tas
 	if result.Error != nil {
 		return nil, fmt.Errorf("查询任务失败: %w", result.Error)
 	}
+	for _, task := range tasks {
+		localTaskTimes(task)
+	}
 	return tasks, nil
 }
 
-func (r *Repository) TryLockTask(ctx context.Context, taskID int64, now time.Time, expiredAt time.Time) error {
+// localTaskTimes 将参与调度计算的时间列按本地时钟重建
+func localTaskTimes(task *model.Hawthorn_task) {
+	task.StartAt = localClockPtr(task.StartAt)
+	task.EndAt = localClockPtr(task.EndAt)
+	task.PausedAt = localClockPtr(task.PausedAt)
+	task.ResumeAt = localClockPtr(task.ResumeAt)
+}
+
+// ClaimTask 抢占任务的一个计划触发时间，同一(task_id, scheduled_at)只有一个节点能成功
+func (r *Repository) ClaimTask(ctx context.Context, claim *model.Hawthorn_task_claim) (bool, error) {
+	result := dbs.InsertOrNothing(ctx, r.db(), claim)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *Repository) DeleteClaimsBefore(ctx context.Context, before time.Duration) (int64, error) {
+	result := r.db().WithContext(ctx).
+		Where("claimed_at < now() - make_interval(secs => ?)", before.Seconds()).
+		Delete(&model.Hawthorn_task_claim{})
+	return result.RowsAffected, result.Error
+}
+
+// taskLock 持有的任务锁，LockedBy为节点ID与traceID，作为锁的持有凭证
+type taskLock struct {
+	TaskID   int64
+	LockedBy string
+	LockedAt time.Time
+	Slot     *int   // 为空时是任务行上的锁，否则为允许并发的任务的执行槽位
+	ShardID  *int64 // 分片的执行租约，LockedBy为分片执行的追踪号
+}
+
+func lockOwner(nodeID string, traceID string) string {
+	return nodeID + ":" + traceID
+}
+
+// localClock timestamp列不含时区，写入的是节点本地时钟，读出时被标记为UTC，按本地时钟重建
+func localClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+func localClockPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := localClock(*t)
+	return &local
+}
+
+func (r *Repository) GetTask(ctx context.Context, taskID int64) (*model.Hawthorn_task, error) {
+	var task model.Hawthorn_task
+	result := r.db().WithContext(ctx).Where("id = ?", taskID).Take(&task)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	localTaskTimes(&task)
+	return &task, nil
+}
+
+func (r *Repository) CreateTrigger(ctx context.Context, trigger *model.Hawthorn_task_trigger) error {
+	return r.db().WithContext(ctx).Create(trigger).Error
+}
+
+// ClaimTriggers 抢占待执行的一次性触发，只抢占本节点已注册处理函数的任务
+func (r *Repository) ClaimTriggers(ctx context.Context, nodeID string, handlers []string, limit int) ([]*model.Hawthorn_task_trigger, error) {
+	var triggers []*model.Hawthorn_task_trigger
+	sql := `update hawthorn_task_trigger set status = ?, node_id = ?, claimed_at = now()
+where id in (
+	select tr.id from hawthorn_task_trigger tr join hawthorn_task t on t.id = tr.task_id
+	where tr.status = ? and coalesce(nullif(t.handler, ''), t.name) in ?
+	order by tr.id limit ?
+	for update of tr skip locked)
+returning *`
+	result := r.db().WithContext(ctx).Raw(sql, triggerClaimed, nodeID, triggerPending, handlers, limit).Scan(&triggers)
+	if result.Error != nil {
+		return nil, fmt.Errorf("抢占任务触发失败: %w", result.Error)
+	}
+	for _, trigger := range triggers {
+		trigger.ScheduledAt = localClockPtr(trigger.ScheduledAt)
+		trigger.CreatedAt = localClockPtr(trigger.CreatedAt)
+	}
+	return triggers, nil
+}
+
+func (r *Repository) DeleteTriggersBefore(ctx context.Context, before time.Duration) (int64, error) {
+	result := r.db().WithContext(ctx).
+		Where("status = ? and claimed_at < now() - make_interval(secs => ?)", triggerClaimed, before.Seconds()).
+		Delete(&model.Hawthorn_task_trigger{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *Repository) TryLockTask(ctx context.Context, taskID int64, owner string, lease time.Duration) (*taskLock, error) {
+	var lockTask model.Hawthorn_task
 	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.WithContext(ctx).Exec("SET LOCAL statement_timeout = 10000").Error; err != nil {
 			return err
 		}
-		var lockTask model.Hawthorn_task
-		result := tx.WithContext(ctx).Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		result := tx.WithContext(ctx).
 			Model(&lockTask).
-			Where("id=? and enabled = true and (expired_at is null or expired_at < ?)", taskID, now).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "locked_at"}}}).
+			Where("id=? and enabled = true and paused = false and (expired_at is null or expired_at < now())", taskID).
 			Updates(map[string]interface{}{
-				"locked_at":  now,
-				"expired_at": expiredAt,
+				"locked_by":  owner,
+				"locked_at":  gorm.Expr("now()"),
+				"expired_at": gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()),
 			})
 		if result.Error != nil {
 			return result.Error
@@ -50,28 +189,561 @@ func (r *Repository) TryLockTask(ctx context.Context, taskID int64, now time.Tim
 		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	if lockTask.LockedAt == nil {
+		return nil, fmt.Errorf("任务锁信息缺失")
+	}
+	return &taskLock{
+		TaskID:   taskID,
+		LockedBy: owner,
+		LockedAt: *lockTask.LockedAt,
+	}, nil
+}
+
+// TryLockSlot 抢占任务的一个空闲执行槽位，槽位已满时返回gorm.ErrRecordNotFound
+func (r *Repository) TryLockSlot(ctx context.Context, taskID int64, owner string, limit int, lease time.Duration) (*taskLock, error) {
+	var slots []model.Hawthorn_task_slot
+	sql := `insert into hawthorn_task_slot (task_id, slot, locked_by, locked_at, expired_at)
+select ?, s, ?, now(), now() + make_interval(secs => ?) from generate_series(0, ?) s
+where exists (select 1 from hawthorn_task t where t.id = ? and t.enabled = true and t.paused = false)
+	and not exists (select 1 from hawthorn_task_slot x where x.task_id = ? and x.slot = s and x.expired_at >= now())
+order by s limit 1
+on conflict (task_id, slot) do update set locked_by = excluded.locked_by, locked_at = excluded.locked_at, expired_at = excluded.expired_at
+where hawthorn_task_slot.expired_at < now()
+returning *`
+	result := r.db().WithContext(ctx).Raw(sql, taskID, owner, lease.Seconds(), limit-1, taskID, taskID).Scan(&slots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(slots) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &taskLock{
+		TaskID:   taskID,
+		LockedBy: owner,
+		LockedAt: slots[0].LockedAt,
+		Slot:     &slots[0].Slot,
+	}, nil
+}
+
+// GetLockHolder 查询任务锁的持有者，锁未被持有时返回空
+func (r *Repository) GetLockHolder(ctx context.Context, taskID int64) (string, error) {
+	var holders []string
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_task{}).
+		Where("id = ? and locked_by is not null and expired_at >= now()", taskID).
+		Pluck("locked_by", &holders)
+	if result.Error != nil || len(holders) == 0 {
+		return "", result.Error
+	}
+	return holders[0], nil
+}
+
+// TakeOverLockTask 从原持有者手中接管任务锁，原持有者已释放时返回gorm.ErrRecordNotFound
+func (r *Repository) TakeOverLockTask(ctx context.Context, taskID int64, from string, owner string, lease time.Duration) (*taskLock, error) {
+	var lockTask model.Hawthorn_task
+	result := r.db().WithContext(ctx).
+		Model(&lockTask).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "locked_at"}}}).
+		Where("id = ? and locked_by = ?", taskID, from).
+		Updates(map[string]interface{}{
+			"locked_by":  owner,
+			"locked_at":  gorm.Expr("now()"),
+			"expired_at": gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || lockTask.LockedAt == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &taskLock{
+		TaskID:   taskID,
+		LockedBy: owner,
+		LockedAt: *lockTask.LockedAt,
+	}, nil
 }
 
-func (r *Repository) ReleaseLockTask(ctx context.Context, taskID int64, now time.Time, expiredAt time.Time, lg *zap.Logger) error {
+// RenewLockTask 续期任务锁，锁已不属于当前持有者时返回gorm.ErrRecordNotFound
+func (r *Repository) RenewLockTask(ctx context.Context, lock *taskLock, lease time.Duration) error {
+	db := r.db().WithContext(ctx).Model(&model.Hawthorn_task{}).
+		Where("id=? and locked_by=?", lock.TaskID, lock.LockedBy)
+	if lock.Slot != nil {
+		db = r.db().WithContext(ctx).Model(&model.Hawthorn_task_slot{}).
+			Where("task_id = ? and slot = ? and locked_by = ?", lock.TaskID, *lock.Slot, lock.LockedBy)
+	}
+	if lock.ShardID != nil {
+		db = r.db().WithContext(ctx).Model(&model.Hawthorn_task_shard{}).
+			Where("id = ? and trace_id = ? and status = ?", *lock.ShardID, lock.LockedBy, stateRunning)
+	}
+	result := db.Update("expired_at", gorm.Expr("now() + make_interval(secs => ?)", lease.Seconds()))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ReleaseLockTask(ctx context.Context, lock *taskLock, lg *zap.Logger) error {
+	if lock.Slot != nil {
+		result := r.db().WithContext(ctx).
+			Where("task_id = ? and slot = ? and locked_by = ?", lock.TaskID, *lock.Slot, lock.LockedBy).
+			Delete(&model.Hawthorn_task_slot{})
+		if result.RowsAffected == 0 && result.Error == nil {
+			lg.Warn("任务槽位已失效，无需释放", zap.Int64("taskID", lock.TaskID), zap.Int("slot", *lock.Slot))
+		}
+		return result.Error
+	}
 	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.WithContext(ctx).Exec("SET LOCAL statement_timeout = 10011").Error; err != nil {
 			return err
 		}
 
 		var lockTask model.Hawthorn_task
-		result := tx.WithContext(ctx).Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		result := tx.WithContext(ctx).
 			Model(&lockTask).
-			Where("id=? and locked_at=? and expired_at=?", taskID, now, expiredAt).
+			Where("id=? and locked_by=?", lock.TaskID, lock.LockedBy).
 			Updates(map[string]interface{}{
+				"locked_by":  nil,
 				"locked_at":  nil,
 				"expired_at": nil,
 			})
+		if result.RowsAffected == 0 {
+			lg.Warn("任务锁已失效，无需释放", zap.Int64("taskID", lock.TaskID))
+		}
 		return result.Error
 	})
 	return err
 }
 
+// GetLockedTasks 查询当前持有锁的任务及执行槽位，expired表示锁已过期但尚未被释放
+func (r *Repository) GetLockedTasks(ctx context.Context) ([]*LockedTask, error) {
+	var tasks []*LockedTask
+	sql := `select id, name, locked_by, locked_at, expired_at, coalesce(expired_at < now(), false) as expired, null::int as slot
+from hawthorn_task where locked_at is not null
+union all
+select t.id, t.name, s.locked_by, s.locked_at, s.expired_at, s.expired_at < now() as expired, s.slot
+from hawthorn_task_slot s join hawthorn_task t on t.id = s.task_id
+order by locked_at`
+	result := r.db().WithContext(ctx).Raw(sql).Scan(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询锁定任务失败: %w", result.Error)
+	}
+	return tasks, nil
+}
+
+// ForceUnlockTask 强制释放任务锁并登记操作记录，返回释放前的锁持有者。
+// slot非空时只释放该执行槽位，否则释放任务行上的锁及全部槽位
+func (r *Repository) ForceUnlockTask(ctx context.Context, op *model.Hawthorn_task_operation, slot *int) (string, error) {
+	var owners []string
+	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var lockTask model.Hawthorn_task
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, locked_by, locked_at").
+			Where("id = ?", op.TaskID).
+			Take(&lockTask)
+		if result.Error != nil {
+			return result.Error
+		}
+		if slot == nil && lockTask.LockedAt != nil {
+			if lockTask.LockedBy != nil {
+				owners = append(owners, *lockTask.LockedBy)
+			}
+			result = tx.Model(&model.Hawthorn_task{}).
+				Where("id = ?", op.TaskID).
+				Updates(map[string]interface{}{
+					"locked_by":  nil,
+					"locked_at":  nil,
+					"expired_at": nil,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		var slots []model.Hawthorn_task_slot
+		db := tx.Clauses(clause.Returning{}).Where("task_id = ?", op.TaskID)
+		if slot != nil {
+			db = db.Where("slot = ?", *slot)
+		}
+		if err := db.Delete(&slots).Error; err != nil {
+			return err
+		}
+		for _, s := range slots {
+			owners = append(owners, s.LockedBy)
+		}
+		if released := slot == nil && lockTask.LockedAt != nil || len(slots) > 0; !released {
+			return ErrTaskNotLocked
+		}
+		op.Detail = strings.TrimSpace(fmt.Sprintf("原持有者:%s %s", strings.Join(owners, ","), op.Detail))
+		return tx.Create(op).Error
+	})
+	return strings.Join(owners, ","), err
+}
+
+// PauseTask 暂停任务并登记操作记录，resumeAt非空时到期自动恢复
+func (r *Repository) PauseTask(ctx context.Context, op *model.Hawthorn_task_operation, resumeAt *time.Time) error {
+	return r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Hawthorn_task{}).
+			Where("id = ?", op.TaskID).
+			Updates(map[string]interface{}{
+				"paused":       true,
+				"paused_at":    gorm.Expr("now()"),
+				"paused_by":    op.Operator,
+				"pause_reason": op.Detail,
+				"resume_at":    resumeAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskNotFound
+		}
+		return tx.Create(op).Error
+	})
+}
+
+// ResumeTask 恢复暂停的任务并登记操作记录
+func (r *Repository) ResumeTask(ctx context.Context, op *model.Hawthorn_task_operation) error {
+	return r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Hawthorn_task{}).
+			Where("id = ? and paused = true", op.TaskID).
+			Updates(resumeColumns())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTaskNotPaused
+		}
+		return tx.Create(op).Error
+	})
+}
+
+// ResumeExpiredPauses 恢复已到自动恢复时间的任务，返回恢复的任务ID
+func (r *Repository) ResumeExpiredPauses(ctx context.Context) ([]int64, error) {
+	var tasks []model.Hawthorn_task
+	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&tasks).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+			Where("paused = true and resume_at <= now()").
+			Updates(resumeColumns())
+		if result.Error != nil || len(tasks) == 0 {
+			return result.Error
+		}
+		ops := make([]model.Hawthorn_task_operation, 0, len(tasks))
+		for _, task := range tasks {
+			ops = append(ops, model.Hawthorn_task_operation{
+				TaskID:   task.ID,
+				Action:   ActionResume,
+				Operator: operatorSystem,
+				Detail:   "到达自动恢复时间",
+			})
+		}
+		return tx.Create(&ops).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("自动恢复暂停任务失败: %w", err)
+	}
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids, nil
+}
+
+func resumeColumns() map[string]interface{} {
+	return map[string]interface{}{
+		"paused":       false,
+		"paused_at":    nil,
+		"paused_by":    nil,
+		"pause_reason": nil,
+		"resume_at":    nil,
+	}
+}
+
+// GetClusterControl 查询集群调度开关，未配置时返回未暂停
+func (r *Repository) GetClusterControl(ctx context.Context) (*model.Hawthorn_cluster_control, error) {
+	var controls []model.Hawthorn_cluster_control
+	result := r.db().WithContext(ctx).Where("id = ?", clusterControlRow).Limit(1).Find(&controls)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询集群调度开关失败: %w", result.Error)
+	}
+	if len(controls) == 0 {
+		return &model.Hawthorn_cluster_control{ID: clusterControlRow}, nil
+	}
+	return &controls[0], nil
+}
+
+func (r *Repository) SaveClusterControl(ctx context.Context, control *model.Hawthorn_cluster_control, op *model.Hawthorn_task_operation) error {
+	return r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		control.UpdatedAt = &now
+		if err := dbs.InsertOrUpdate(ctx, tx, control).Error; err != nil {
+			return err
+		}
+		return tx.Create(op).Error
+	})
+}
+
+func (r *Repository) GetMaintenanceWindows(ctx context.Context) ([]*model.Hawthorn_maintenance_window, error) {
+	var windows []*model.Hawthorn_maintenance_window
+	result := r.db().WithContext(ctx).Where("enabled = ?", true).Find(&windows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询维护窗口失败: %w", result.Error)
+	}
+	return windows, nil
+}
+
+// GetCalendars 查询全部日历及其例外日期，按日历ID分组
+func (r *Repository) GetCalendars(ctx context.Context) ([]*model.Hawthorn_calendar, map[int64][]*model.Hawthorn_calendar_day, error) {
+	var calendars []*model.Hawthorn_calendar
+	if err := r.db().WithContext(ctx).Find(&calendars).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询日历失败: %w", err)
+	}
+	var days []*model.Hawthorn_calendar_day
+	if err := r.db().WithContext(ctx).Find(&days).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询日历日期失败: %w", err)
+	}
+	grouped := make(map[int64][]*model.Hawthorn_calendar_day)
+	for _, day := range days {
+		grouped[day.CalendarID] = append(grouped[day.CalendarID], day)
+	}
+	return calendars, grouped, nil
+}
+
+// ImportCalendar 按名称新建或更新日历，导入的日期已存在时覆盖
+func (r *Repository) ImportCalendar(ctx context.Context, calendar *model.Hawthorn_calendar, days []*model.Hawthorn_calendar_day, op *model.Hawthorn_task_operation) error {
+	return r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		calendar.UpdatedAt = &now
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "weekends", "updated_at"}),
+		}, clause.Returning{Columns: []clause.Column{{Name: "id"}}}).Create(calendar).Error
+		if err != nil {
+			return fmt.Errorf("保存日历失败: %w", err)
+		}
+		for _, day := range days {
+			day.CalendarID = calendar.ID
+		}
+		if len(days) > 0 {
+			err = tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "calendar_id"}, {Name: "date"}},
+				DoUpdates: clause.AssignmentColumns([]string{"day_type", "name"}),
+			}).CreateInBatches(days, 500).Error
+			if err != nil {
+				return fmt.Errorf("保存日历日期失败: %w", err)
+			}
+		}
+		return tx.Create(op).Error
+	})
+}
+
+// GetUpstreamResults 查询下游任务在scheduledAt各上游最近一次结束的执行保存的结果，按上游任务名返回
+func (r *Repository) GetUpstreamResults(ctx context.Context, taskID int64, scheduledAt time.Time) (map[string]json.RawMessage, error) {
+	var rows []struct {
+		Name   string
+		Result json.RawMessage
+	}
+	sql := `select t.name, (select e.result from hawthorn_task_execution e
+	where e.task_id = d.upstream_id and e.scheduled_at = ? and e.status in ?
+	order by e.end_time desc limit 1) as result
+from hawthorn_task_dependency d join hawthorn_task t on t.id = d.upstream_id
+where d.task_id = ?`
+	if err := r.db().WithContext(ctx).Raw(sql, scheduledAt, finishedStates, taskID).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询上游任务结果失败: %w", err)
+	}
+	results := make(map[string]json.RawMessage, len(rows))
+	for _, row := range rows {
+		if len(row.Result) > 0 {
+			results[row.Name] = row.Result
+		}
+	}
+	return results, nil
+}
+
+func (r *Repository) GetDownstreamIDs(ctx context.Context, upstreamID int64) ([]int64, error) {
+	var ids []int64
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_task_dependency{}).
+		Where("upstream_id = ?", upstreamID).
+		Distinct().Pluck("task_id", &ids)
+	return ids, result.Error
+}
+
+// GetDependencyStates 查询下游任务的各上游在scheduledAt最近一次结束的执行状态
+func (r *Repository) GetDependencyStates(ctx context.Context, taskID int64, scheduledAt time.Time) ([]dependencyState, error) {
+	var states []dependencyState
+	sql := `select d.upstream_id, d.condition, (select e.status from hawthorn_task_execution e
+	where e.task_id = d.upstream_id and e.scheduled_at = ? and e.status in ?
+	order by e.end_time desc limit 1) as status
+from hawthorn_task_dependency d
+where d.task_id = ?`
+	result := r.db().WithContext(ctx).Raw(sql, scheduledAt, finishedStates, taskID).Scan(&states)
+	return states, result.Error
+}
+
+func (r *Repository) GetDependencies(ctx context.Context) ([]*model.Hawthorn_task_dependency, error) {
+	var deps []*model.Hawthorn_task_dependency
+	if err := r.db().WithContext(ctx).Order("id").Find(&deps).Error; err != nil {
+		return nil, fmt.Errorf("查询任务依赖失败: %w", err)
+	}
+	return deps, nil
+}
+
+func (r *Repository) SaveDependencies(ctx context.Context, taskID int64, deps []*model.Hawthorn_task_dependency, op *model.Hawthorn_task_operation) error {
+	return r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).Delete(&model.Hawthorn_task_dependency{}).Error; err != nil {
+			return fmt.Errorf("删除任务依赖失败: %w", err)
+		}
+		if len(deps) > 0 {
+			if err := tx.Create(&deps).Error; err != nil {
+				return fmt.Errorf("保存任务依赖失败: %w", err)
+			}
+		}
+		return tx.Create(op).Error
+	})
+}
+
+func (r *Repository) CreateShards(ctx context.Context, shards []*model.Hawthorn_task_shard) error {
+	return r.db().WithContext(ctx).Create(&shards).Error
+}
+
+// ClaimShard 抢占一个待执行或租约已过期的分片，只抢占本节点已注册处理函数的任务，没有可抢占的分片时返回nil
+func (r *Repository) ClaimShard(ctx context.Context, nodeID string, traceID string, handlers []string, lease time.Duration) (*model.Hawthorn_task_shard, error) {
+	var shards []*model.Hawthorn_task_shard
+	sql := `update hawthorn_task_shard set status = ?, node_id = ?, trace_id = ?, start_time = now(), end_time = null,
+	expired_at = now() + make_interval(secs => ?)
+where id = (
+	select s.id from hawthorn_task_shard s join hawthorn_task t on t.id = s.task_id
+	where (s.status = ? or (s.status = ? and s.expired_at < now())) and coalesce(nullif(t.handler, ''), t.name) in ?
+	order by s.id limit 1
+	for update of s skip locked)
+returning *`
+	result := r.db().WithContext(ctx).Raw(sql, stateRunning, nodeID, traceID, lease.Seconds(),
+		shardPending, stateRunning, handlers).Scan(&shards)
+	if result.Error != nil {
+		return nil, fmt.Errorf("抢占任务分片失败: %w", result.Error)
+	}
+	if len(shards) == 0 {
+		return nil, nil
+	}
+	shards[0].ScheduledAt = localClockPtr(shards[0].ScheduledAt)
+	return shards[0], nil
+}
+
+// FinishShard 登记分片执行结果，分片已被取消或被其他节点重新抢占时不更新
+func (r *Repository) FinishShard(ctx context.Context, shard *model.Hawthorn_task_shard) error {
+	return r.db().WithContext(ctx).Model(&model.Hawthorn_task_shard{}).
+		Where("id = ? and trace_id = ? and status = ?", shard.ID, shard.TraceID, stateRunning).
+		Select("status", "end_time", "error", "attempts").
+		Updates(shard).Error
+}
+
+func (r *Repository) GetShards(ctx context.Context, parentTraceID string) ([]*model.Hawthorn_task_shard, error) {
+	var shards []*model.Hawthorn_task_shard
+	if err := r.db().WithContext(ctx).Where("parent_trace_id = ?", parentTraceID).Order("shard_index").Find(&shards).Error; err != nil {
+		return nil, fmt.Errorf("查询任务分片失败: %w", err)
+	}
+	return shards, nil
+}
+
+// CancelShards 取消父执行下尚未结束的分片，执行中的分片续期失败后停止
+func (r *Repository) CancelShards(ctx context.Context, parentTraceID string, reason string) error {
+	return r.db().WithContext(ctx).Model(&model.Hawthorn_task_shard{}).
+		Where("parent_trace_id = ? and status in ?", parentTraceID, []string{shardPending, stateRunning}).
+		Updates(map[string]interface{}{
+			"status":   stateCancelled,
+			"end_time": gorm.Expr("now()"),
+			"error":    reason,
+		}).Error
+}
+
+// RetryShard 将失败的分片重新置为待执行
+func (r *Repository) RetryShard(ctx context.Context, shardID int64, op *model.Hawthorn_task_operation) (*model.Hawthorn_task_shard, error) {
+	var shards []*model.Hawthorn_task_shard
+	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&shards).
+			Clauses(clause.Returning{}).
+			Where("id = ? and status in ?", shardID, []string{stateFiled, stateTimeout, stateCancelled}).
+			Updates(map[string]interface{}{
+				"status":  shardPending,
+				"error":   "",
+				"retries": gorm.Expr("retries + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(shards) == 0 {
+			return ErrShardNotRetryable
+		}
+		op.TaskID = shards[0].TaskID
+		return tx.Create(op).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shards[0], nil
+}
+
+// DeleteShardsBefore 清理已结束超过保留时长的分片
+func (r *Repository) DeleteShardsBefore(ctx context.Context, before time.Duration) (int64, error) {
+	result := r.db().WithContext(ctx).
+		Where("status not in ? and end_time < now() - make_interval(secs => ?)", []string{shardPending, stateRunning}, before.Seconds()).
+		Delete(&model.Hawthorn_task_shard{})
+	return result.RowsAffected, result.Error
+}
+
+// FinishShardParent 重试的分片全部结束后更新父执行记录，父执行仍在等待分片时不更新
+func (r *Repository) FinishShardParent(ctx context.Context, parentTraceID string, status string, errMsg string) error {
+	return r.db().WithContext(ctx).Model(&model.Hawthorn_task_execution{}).
+		Where("trace_id = ? and status <> ?", parentTraceID, stateRunning).
+		Updates(map[string]interface{}{
+			"status":   status,
+			"error":    errMsg,
+			"end_time": gorm.Expr("now()"),
+		}).Error
+}
+
+// SaveNode 登记节点信息，节点已存在时更新并刷新心跳时间
+func (r *Repository) SaveNode(ctx context.Context, node *model.Hawthorn_node) error {
+	return r.db().WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "node_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ip", "app_name", "version", "handlers", "start_time", "heartbeat_at"}),
+	}).Create(node).Error
+}
+
+func (r *Repository) DeleteNode(ctx context.Context, nodeID string) error {
+	return r.db().WithContext(ctx).Where("node_id = ?", nodeID).Delete(&model.Hawthorn_node{}).Error
+}
+
+// DeleteStaleNodes 清理心跳超时的节点
+func (r *Repository) DeleteStaleNodes(ctx context.Context, timeout time.Duration) (int64, error) {
+	result := r.db().WithContext(ctx).
+		Where("heartbeat_at < now() - make_interval(secs => ?)", timeout.Seconds()).
+		Delete(&model.Hawthorn_node{})
+	return result.RowsAffected, result.Error
+}
+
+// GetNodes 查询集群节点，心跳在aliveWithin内的节点为存活
+func (r *Repository) GetNodes(ctx context.Context, aliveWithin time.Duration) ([]*NodeInfo, error) {
+	var nodes []*NodeInfo
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_node{}).
+		Select("*, heartbeat_at >= now() - make_interval(secs => ?) as alive", aliveWithin.Seconds()).
+		Order("node_id").
+		Scan(&nodes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询集群节点失败: %w", result.Error)
+	}
+	return nodes, nil
+}
+
+func (r *Repository) CreateOperation(ctx context.Context, op *model.Hawthorn_task_operation) error {
+	return r.db().WithContext(ctx).Create(op).Error
+}
+
 func (r *Repository) CreateExecution(ctx context.Context, execution *model.Hawthorn_task_execution) error {
 	result := r.db().WithContext(ctx).Create(execution)
 	if result.Error != nil {
@@ -79,3 +751,95 @@ func (r *Repository) CreateExecution(ctx context.Context, execution *model.Hawth
 	}
 	return nil
 }
+
+// FinishExecution 任务结束后更新执行记录
+func (r *Repository) FinishExecution(ctx context.Context, execution *model.Hawthorn_task_execution) error {
+	result := r.db().WithContext(ctx).Model(execution).
+		Select("status", "end_time", "error", "attempts", "result").
+		Updates(execution)
+	return result.Error
+}
+
+// AbandonRunningExecutions 将节点在before之前开始、遗留的running执行记录标记为abandoned，任务锁仍在续期的记录除外。
+// 按节点ID匹配，多个节点共用节点ID时会误处理其他节点不持有锁的执行（如不限制并发的任务）
+func (r *Repository) AbandonRunningExecutions(ctx context.Context, nodeID string, before time.Time) (int64, error) {
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_task_execution{}).
+		Where("node_id = ? and status = ? and start_time < ?", nodeID, stateRunning, before).
+		Where("not exists (select 1 from hawthorn_task t where t.locked_by = node_id || ':' || trace_id and t.expired_at > now())").
+		Where("not exists (select 1 from hawthorn_task_slot s where s.locked_by = node_id || ':' || trace_id and s.expired_at > now())").
+		Updates(map[string]interface{}{
+			"status":   stateAbandoned,
+			"end_time": gorm.Expr("now()"),
+			"error":    "节点重启，执行记录未正常结束",
+		})
+	return result.RowsAffected, result.Error
+}
+
+// RequestCancel 登记取消请求，返回任务ID。按执行记录、分片、任务锁及执行槽位依次查找执行中的追踪号，
+// 均未找到时返回ErrExecutionNotRunning；不记录执行记录时，不限制并发的任务无法查找
+func (r *Repository) RequestCancel(ctx context.Context, traceID string, operator string) (int64, error) {
+	var taskID int64
+	err := r.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var executions []model.Hawthorn_task_execution
+		result := tx.Model(&executions).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "task_id"}}}).
+			Where("trace_id = ? and status = ?", traceID, stateRunning).
+			Updates(map[string]interface{}{
+				"cancel_at": gorm.Expr("now()"),
+				"cancel_by": operator,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		var ids []int64
+		if len(executions) > 0 {
+			ids = append(ids, executions[0].TaskID)
+		} else {
+			owner := "%:" + traceID
+			err := tx.Raw(`select task_id from hawthorn_task_shard where trace_id = ? and status = ?
+union all select id from hawthorn_task where locked_by like ? and expired_at >= now()
+union all select task_id from hawthorn_task_slot where locked_by like ? and expired_at >= now()
+limit 1`, traceID, stateRunning, owner, owner).Scan(&ids).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) == 0 {
+			return ErrExecutionNotRunning
+		}
+		taskID = ids[0]
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Hawthorn_task_cancel{
+			TraceID:  traceID,
+			TaskID:   taskID,
+			CancelBy: operator,
+		}).Error
+	})
+	return taskID, err
+}
+
+func (r *Repository) GetCancelRequested(ctx context.Context, traceIDs []string) ([]string, error) {
+	var ids []string
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_task_cancel{}).
+		Where("trace_id in ?", traceIDs).
+		Pluck("trace_id", &ids)
+	return ids, result.Error
+}
+
+func (r *Repository) DeleteCancelsBefore(ctx context.Context, before time.Duration) (int64, error) {
+	result := r.db().WithContext(ctx).
+		Where("created_at < now() - make_interval(secs => ?)", before.Seconds()).
+		Delete(&model.Hawthorn_task_cancel{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *Repository) GetLastSuccessTime(ctx context.Context, taskID int64) (*time.Time, error) {
+	var last *time.Time
+	result := r.db().WithContext(ctx).Model(&model.Hawthorn_task_execution{}).
+		Select("max(coalesce(scheduled_at, start_time))").
+		Where("task_id = ? and status = ?", taskID, stateSuccess).
+		Scan(&last)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询最近成功执行失败: %w", result.Error)
+	}
+	return localClockPtr(last), nil
+}
@@ -0,0 +1,77 @@
+package cron
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+const (
+	RetryFixed             = "fixed"
+	RetryLinear            = "linear"
+	RetryExponential       = "exponential"
+	RetryExponentialJitter = "exponential_jitter"
+)
+
+const (
+	defaultRetryInterval    = time.Second
+	defaultRetryMaxInterval = time.Minute
+)
+
+type retryPolicy struct {
+	kind        string
+	interval    time.Duration
+	maxInterval time.Duration
+}
+
+func newRetryPolicy(task *model.Hawthorn_task) retryPolicy {
+	p := retryPolicy{
+		kind:        task.RetryPolicy,
+		interval:    time.Duration(task.RetryInterval) * time.Millisecond,
+		maxInterval: time.Duration(task.RetryMaxInterval) * time.Millisecond,
+	}
+	if p.interval <= 0 {
+		p.interval = defaultRetryInterval
+	}
+	if p.maxInterval <= 0 {
+		p.maxInterval = defaultRetryMaxInterval
+	}
+	return p
+}
+
+// delay 第retry次重试前的等待时间，retry从1开始
+func (p retryPolicy) delay(retry int) time.Duration {
+	var d time.Duration
+	switch p.kind {
+	case RetryLinear:
+		d = p.interval * time.Duration(retry)
+	case RetryExponential, RetryExponentialJitter:
+		d = p.interval
+		for i := 1; i < retry && d < p.maxInterval; i++ {
+			d *= 2
+		}
+	default:
+		d = p.interval
+	}
+	if d > p.maxInterval {
+		d = p.maxInterval
+	}
+	if p.kind == RetryExponentialJitter {
+		d = d/2 + rand.N(d/2+1)
+	}
+	return d
+}
+
+// sleepContext 等待d，ctx结束时提前返回其原因
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
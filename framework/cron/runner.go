@@ -0,0 +1,91 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"go.uber.org/zap"
+)
+
+var (
+	errTaskTimeout = errors.New("任务执行超时")
+	errNodeStopped = errors.New("任务管理器已停止")
+)
+
+// taskDeadline 任务执行超时时间，未配置时不限制
+func taskDeadline(task *model.Hawthorn_task) time.Duration {
+	if task.Timeout <= 0 {
+		return 0
+	}
+	return time.Duration(task.Timeout) * time.Second
+}
+
+// taskContext 任务函数使用的上下文，超时或任务管理器停止时结束
+func (m *TaskManager) taskContext(ctx context.Context, task *model.Hawthorn_task) (context.Context, context.CancelCauseFunc) {
+	c, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(m.ctx, func() {
+		cancel(errNodeStopped)
+	})
+	cancelTimeout := context.CancelFunc(func() {})
+	if d := taskDeadline(task); d > 0 {
+		c, cancelTimeout = context.WithTimeoutCause(c, d, errTaskTimeout)
+	}
+	return c, func(cause error) {
+		stop()
+		cancel(cause)
+		cancelTimeout()
+	}
+}
+
+// runTaskFunc 在独立协程中执行任务函数，ctx结束后最多再等待grace，超过则放弃该协程
+func runTaskFunc(ctx context.Context, h *taskHandler, params TaskParams, grace time.Duration, lg *zap.Logger) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("任务函数异常：%v", err)
+			}
+		}()
+		done <- h.fn(ctx, params)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		lg.Error("任务函数未响应取消，放弃等待，协程泄漏",
+			zap.Int64("taskID", params.TaskID),
+			zap.String("taskName", params.TaskName),
+			zap.Duration("grace", grace),
+			zap.Error(context.Cause(ctx)))
+		return context.Cause(ctx)
+	}
+}
+
+func newAttempt(retry int, start time.Time, err error) model.TaskAttempt {
+	attempt := model.TaskAttempt{
+		Attempt:   retry,
+		StartTime: start.Truncate(time.Millisecond),
+		Duration:  time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt
+}
+
+// isTimeout 判断任务是否因超时结束
+func isTimeout(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errTaskTimeout)
+}
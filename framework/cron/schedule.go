@@ -0,0 +1,184 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"github.com/robfig/cron/v3"
+)
+
+var errEmptySchedule = errors.New("未配置cron表达式")
+
+var scheduleParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// taskLocation 任务配置的时区，未配置时为节点时区
+func taskLocation(task *model.Hawthorn_task) (*time.Location, error) {
+	if task.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(task.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("时区[%s]无效: %w", task.Timezone, err)
+	}
+	return loc, nil
+}
+
+// hasSchedule 任务是否配置了cron表达式，未配置的任务只由依赖或手动触发
+func hasSchedule(task *model.Hawthorn_task) bool {
+	for _, expr := range append([]string{task.CronExpr}, task.Schedules...) {
+		if strings.TrimSpace(expr) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSchedule 按任务配置生成调度计划，cron表达式按任务时区计算，多个表达式取并集
+func parseSchedule(task *model.Hawthorn_task) (cron.Schedule, error) {
+	var loc *time.Location
+	if task.Timezone != "" {
+		var err error
+		if loc, err = taskLocation(task); err != nil {
+			return nil, err
+		}
+	}
+	var union unionSchedule
+	for _, expr := range append([]string{task.CronExpr}, task.Schedules...) {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		s, err := parseTaskExpr(expr, task.Name, loc)
+		if err != nil {
+			return nil, fmt.Errorf("cron表达式[%s]错误: %w", expr, err)
+		}
+		union = append(union, s)
+	}
+	switch len(union) {
+	case 0:
+		return nil, errEmptySchedule
+	case 1:
+		return union[0], nil
+	}
+	return union, nil
+}
+
+// parseTaskExpr 展开H后解析表达式，H按任务名哈希，同一任务在各节点上取值一致
+func parseTaskExpr(expr string, name string, loc *time.Location) (cron.Schedule, error) {
+	expr, err := expandHashTokens(expr, name)
+	if err != nil {
+		return nil, err
+	}
+	return parseCronExpr(expr, loc)
+}
+
+// scheduleKey 影响调度计划的任务配置，变化时需要重新加入调度器
+func scheduleKey(task *model.Hawthorn_task) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%s", task.Name, task.CronExpr, strings.Join(task.Schedules, ";"), task.Timezone,
+		task.Calendar, task.CalendarPolicy, timeKey(task.StartAt), timeKey(task.EndAt))
+}
+
+func timeKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// unionSchedule 多个调度计划的并集，取最早的下一次触发时间
+type unionSchedule []cron.Schedule
+
+func (u unionSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range u {
+		n := s.Next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// boundedSchedule 只在生效时间与失效时间之间触发
+type boundedSchedule struct {
+	cron.Schedule
+	start *time.Time
+	end   *time.Time
+}
+
+func newBoundedSchedule(sched cron.Schedule, task *model.Hawthorn_task) (cron.Schedule, error) {
+	if task.StartAt == nil && task.EndAt == nil {
+		return sched, nil
+	}
+	if task.StartAt != nil && task.EndAt != nil && !task.StartAt.Before(*task.EndAt) {
+		return nil, fmt.Errorf("生效时间%v不早于失效时间%v", task.StartAt, task.EndAt)
+	}
+	return &boundedSchedule{Schedule: sched, start: task.StartAt, end: task.EndAt}, nil
+}
+
+func (s *boundedSchedule) Next(t time.Time) time.Time {
+	if s.start != nil && t.Before(*s.start) {
+		// Next返回严格晚于参数的时间，退回1纳秒使生效时间本身可以触发
+		t = s.start.Add(-time.Nanosecond)
+	}
+	next := s.Schedule.Next(t)
+	if s.end != nil && !next.Before(*s.end) {
+		return time.Time{}
+	}
+	return next
+}
+
+// alignedSchedule @every按固定零点对齐触发时间。robfig/cron从加入调度的时刻起计算间隔，
+// 各节点得到的计划时间不同，抢占无法去重
+type alignedSchedule struct {
+	delay time.Duration
+}
+
+func (s alignedSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.delay).Add(s.delay)
+}
+
+// maxPendingFires 未被任务取走的触发时间上限，超过时丢弃最早的
+const maxPendingFires = 16
+
+// firedSchedule 记录调度器计算出的触发时间，任务触发时据此得到本次的计划触发时间。
+// robfig/cron 每次调用Next得到的时间都会按顺序触发一次，因此按先进先出的顺序记录，
+// 每次触发取走最早的一个，不受任务协程与下一次Next调用先后的影响。
+type firedSchedule struct {
+	cron.Schedule
+	mu      sync.Mutex
+	pending []time.Time
+}
+
+func newFiredSchedule(sched cron.Schedule) *firedSchedule {
+	return &firedSchedule{Schedule: sched}
+}
+
+func (s *firedSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t)
+	if next.IsZero() {
+		return next
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, next)
+	if len(s.pending) > maxPendingFires {
+		s.pending = s.pending[1:]
+	}
+	s.mu.Unlock()
+	return next
+}
+
+// fired 本次触发对应的计划时间
+func (s *firedSchedule) fired() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return time.Now().Truncate(time.Second)
+	}
+	t := s.pending[0]
+	s.pending = s.pending[1:]
+	return t
+}
@@ -0,0 +1,45 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+// robfig/cron先启动任务协程再计算下一次触发，任务协程可能在多次Next之后才读取
+func TestFiredScheduleOrder(t *testing.T) {
+	sched, err := scheduleParser.Parse("* * * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newFiredSchedule(sched)
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	first := fs.Next(start)
+	second := fs.Next(first)
+	third := fs.Next(second)
+
+	for i, want := range []time.Time{first, second, third} {
+		if got := fs.fired(); !got.Equal(want) {
+			t.Fatalf("第%d次触发 got %v, want %v", i+1, got, want)
+		}
+	}
+	if !first.Before(second) || !second.Before(third) {
+		t.Fatalf("触发时间未递增: %v %v %v", first, second, third)
+	}
+}
+
+func TestFiredScheduleNextBeforeFired(t *testing.T) {
+	sched, err := scheduleParser.Parse("0 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newFiredSchedule(sched)
+	start := time.Date(2026, 3, 1, 9, 30, 0, 0, time.Local)
+	fs.Next(start)
+	fs.Next(time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local))
+	if got, want := fs.fired(), time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := fs.fired(), time.Date(2026, 3, 1, 11, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"go.uber.org/zap"
+)
+
+const shardPending = "pending"
+
+// 每次轮询最多抢占的分片数
+const shardBatchSize = 10
+
+var ErrShardNotRetryable = errors.New("分片不存在或未失败")
+
+// runShards 将本次触发拆分为分片，等待各节点执行完全部分片。
+// 父执行被取消或超时时取消未结束的分片；节点停止时分片继续由其他节点执行，全部结束后更新父执行记录。
+func (m *TaskManager) runShards(ctx context.Context, task *model.Hawthorn_task, traceID string, fire taskFire, params json.RawMessage, lg *zap.Logger) error {
+	shards := make([]*model.Hawthorn_task_shard, task.ShardCount)
+	for i := range shards {
+		shards[i] = &model.Hawthorn_task_shard{
+			ParentTraceID: traceID,
+			TaskID:        task.ID,
+			ShardIndex:    i,
+			ShardTotal:    task.ShardCount,
+			ScheduledAt:   &fire.scheduledAt,
+			TriggerType:   fire.triggerType,
+			Params:        params,
+			Status:        shardPending,
+		}
+	}
+	if err := m.repo.CreateShards(ctx, shards); err != nil {
+		return fmt.Errorf("登记任务分片失败: %w", err)
+	}
+	lg.Info("任务已拆分为分片，等待各节点执行", zap.Int64("taskID", task.ID), zap.Int("shardTotal", task.ShardCount))
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			cause := context.Cause(ctx)
+			if !errors.Is(cause, errNodeStopped) {
+				if err := m.repo.CancelShards(context.WithoutCancel(ctx), traceID, cause.Error()); err != nil {
+					lg.Error("取消任务分片失败", zap.Error(err))
+				}
+			}
+			return cause
+		case <-ticker.C:
+		}
+		shards, err := m.repo.GetShards(ctx, traceID)
+		if err != nil {
+			lg.Warn("查询任务分片失败", zap.Error(err))
+			continue
+		}
+		if done, err := shardsResult(shards); done {
+			return err
+		}
+	}
+}
+
+// shardsResult 分片是否全部结束，有分片未成功时返回失败的分片序号
+func shardsResult(shards []*model.Hawthorn_task_shard) (bool, error) {
+	var failed []int
+	for _, shard := range shards {
+		switch shard.Status {
+		case shardPending, stateRunning:
+			return false, nil
+		case stateSuccess, stateSkipped:
+		default:
+			failed = append(failed, shard.ShardIndex)
+		}
+	}
+	if len(failed) > 0 {
+		return true, fmt.Errorf("分片%v执行失败", failed)
+	}
+	return true, nil
+}
+
+// pollShards 抢占并执行待执行的分片
+func (m *TaskManager) pollShards() {
+	handlers := m.handlerNames()
+	if len(handlers) == 0 {
+		return
+	}
+	limit := shardBatchSize
+	if n := m.pool.available(); n >= 0 {
+		limit = min(limit, n)
+	}
+	for i := 0; i < limit; i++ {
+		traceID, ctx, lg := createContext()
+		shard, err := m.repo.ClaimShard(m.ctx, m.nodeID, traceID, handlers, m.leaseTTL)
+		if err != nil {
+			m.logger.Errorf("%v", err)
+			return
+		}
+		if shard == nil {
+			return
+		}
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					lg.Sugar().Errorf("任务分片执行异常:%v", err)
+				}
+			}()
+			m.executeShard(ctx, shard, lg)
+		}()
+	}
+}
+
+// executeShard 执行抢占到的分片。节点停止或排队超时时不登记结果，租约过期后由其他节点重新抢占
+func (m *TaskManager) executeShard(ctx context.Context, shard *model.Hawthorn_task_shard, lg *zap.Logger) {
+	lg = lg.With(zap.Int64("taskID", shard.TaskID), zap.String("parentTraceID", shard.ParentTraceID), zap.Int("shardIndex", shard.ShardIndex))
+	task, err := m.repo.GetTask(ctx, shard.TaskID)
+	if err != nil {
+		lg.Error("查询分片的任务失败", zap.Error(err))
+		return
+	}
+	handler, exists := m.getHandler(task.HandlerName())
+	if !exists {
+		lg.Error(noFunc)
+		return
+	}
+	release, _, err := m.pool.acquire(m.ctx, task.TaskGroup, task.Priority, m.queueTimeout)
+	if err != nil {
+		lg.Warn("分片未能取得执行名额，租约过期后重新抢占", zap.Error(err))
+		return
+	}
+	defer release()
+
+	taskCtx, cancel := m.taskContext(ctx, task)
+	defer cancel(nil)
+	stopLease := m.keepLease(taskCtx, cancel, &taskLock{TaskID: task.ID, LockedBy: shard.TraceID, ShardID: &shard.ID}, lg)
+	m.registerRunning(&runningExecution{TaskID: task.ID, TraceID: shard.TraceID, StartTime: time.Now(), cancel: cancel})
+	defer m.unregisterRunning(shard.TraceID)
+
+	lg.Info("任务分片开始执行", zap.Int("shardTotal", shard.ShardTotal))
+	var scheduledAt time.Time
+	if shard.ScheduledAt != nil {
+		scheduledAt = *shard.ScheduledAt
+	}
+	attempts, skip, err := m.runAttempts(taskCtx, task, handler, TaskParams{
+		TaskID:      task.ID,
+		TaskName:    task.Name,
+		Params:      shard.Params,
+		ScheduledAt: scheduledAt,
+		Misfire:     shard.TriggerType == TriggerMisfire,
+		TriggerType: shard.TriggerType,
+		ShardIndex:  shard.ShardIndex,
+		ShardTotal:  shard.ShardTotal,
+	}, lg)
+	stopLease()
+	if errors.Is(context.Cause(taskCtx), errNodeStopped) {
+		lg.Warn("任务管理器已停止，分片租约过期后由其他节点重新执行")
+		return
+	}
+
+	end := time.Now().Truncate(time.Millisecond)
+	shard.EndTime = &end
+	shard.Attempts = attempts
+	switch {
+	case err == nil && skip != "":
+		shard.Status = stateSkipped
+		shard.Error = skip
+	case err == nil:
+		shard.Status = stateSuccess
+	case isCancelled(taskCtx):
+		shard.Status = stateCancelled
+		shard.Error = fmt.Sprintf("任务取消：%v", err)
+	case isTimeout(taskCtx):
+		shard.Status = stateTimeout
+		shard.Error = fmt.Sprintf("任务超时：%v", err)
+	default:
+		shard.Status = stateFiled
+		shard.Error = fmt.Sprintf("任务失败：%v", err)
+	}
+	if err := m.repo.FinishShard(ctx, shard); err != nil {
+		lg.Error("登记分片执行结果失败", zap.Error(err))
+		return
+	}
+	lg.Info("任务分片执行结束", zap.String("status", shard.Status), zap.String("error", shard.Error))
+	m.finishShardParent(ctx, shard.ParentTraceID, lg)
+}
+
+// finishShardParent 父执行已结束（如重试失败分片、父执行节点已停止）时，由完成最后一个分片的节点更新父执行记录
+func (m *TaskManager) finishShardParent(ctx context.Context, parentTraceID string, lg *zap.Logger) {
+	if noRecordExecution {
+		return
+	}
+	shards, err := m.repo.GetShards(ctx, parentTraceID)
+	if err != nil {
+		lg.Error("查询任务分片失败", zap.Error(err))
+		return
+	}
+	done, err := shardsResult(shards)
+	if !done {
+		return
+	}
+	status, msg := stateSuccess, ""
+	if err != nil {
+		status, msg = stateFiled, fmt.Sprintf("任务失败：%v", err)
+	}
+	if err := m.repo.FinishShardParent(ctx, parentTraceID, status, msg); err != nil {
+		lg.Error("更新分片任务的执行记录失败", zap.Error(err))
+	}
+}
+
+// RetryShard 重新执行失败、超时或取消的分片，全部分片结束后更新父执行记录
+func (m *TaskManager) RetryShard(ctx context.Context, shardID int64) error {
+	operator := contextString(ctx, "user_id")
+	op := &model.Hawthorn_task_operation{
+		Action:   ActionRetryShard,
+		Operator: operator,
+		Detail:   fmt.Sprintf("shardID:%d", shardID),
+		TraceID:  contextString(ctx, "traceID"),
+	}
+	shard, err := m.repo.RetryShard(ctx, shardID, op)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("%s重试任务[%v]执行[%s]的分片%d", operator, shard.TaskID, shard.ParentTraceID, shard.ShardIndex)
+	return nil
+}
@@ -2,14 +2,14 @@ package cron
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hawthorntrees/cronframework/framework/config"
 	"github.com/hawthorntrees/cronframework/framework/utils"
 	"gorm.io/gorm"
-	"reflect"
-	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hawthorntrees/cronframework/framework/model"
@@ -20,72 +20,136 @@ import (
 type TaskFunc func(ctx context.Context, params TaskParams) error
 
 type TaskParams struct {
-	TaskID     int64
-	TaskName   string
-	RetryCount int
+	TaskID      int64
+	TaskName    string
+	RetryCount  int
+	Params      json.RawMessage // 任务配置的JSON参数，通过Bind解码
+	ScheduledAt time.Time       // 本次执行对应的计划触发时间，补偿执行时为错过的触发时间
+	Misfire     bool            // 是否为停机错过后的补偿执行
+	TriggerType string          // cron, misfire, manual, dependency
+
+	UpstreamResults map[string]json.RawMessage // 依赖触发时，上游任务名 -> 上游执行保存的结果
+	ShardIndex      int                        // 分片任务的分片序号，从0开始
+	ShardTotal      int                        // 分片总数，非分片任务为0
+	result          *taskResult
+}
+
+const (
+	TriggerCron    = "cron"
+	TriggerMisfire = "misfire"
+	TriggerManual  = "manual"
+	// TriggerDependency 上游任务执行结束后触发
+	TriggerDependency = "dependency"
+)
+
+// taskFire 一次任务触发的信息
+type taskFire struct {
+	scheduledAt time.Time
+	triggerType string
+	triggerBy   string
+	params      json.RawMessage // 非空时覆盖任务配置的参数
 }
 
 type TaskManager struct {
-	nodeID       string
-	cron         *cron.Cron
-	taskFuncs    map[string]TaskFunc
-	taskEntries  map[int64]cronEntryInfo // 任务ID -> 定时任务信息
-	repo         *Repository
-	syncInterval time.Duration
-	logger       *zap.SugaredLogger
-	mu           sync.RWMutex
-	ctx          context.Context
-	cancel       context.CancelFunc
+	nodeID         string
+	cron           *cron.Cron
+	taskFuncs      map[string]*taskHandler      // 注册名 -> 任务函数
+	taskEntries    map[int64]cronEntryInfo      // 任务ID -> 定时任务信息
+	running        map[string]*runningExecution // traceID -> 本节点执行中的任务
+	windows        []*maintenanceWindow
+	calendars      map[string]*businessCalendar // 日历名称 -> 工作日历
+	pool           *workerPool
+	queueTimeout   time.Duration
+	startTime      time.Time
+	recheckMisfire atomic.Bool // 数据库访问失败后，下次同步时检查期间错过的触发
+	nodeExpiry     time.Duration
+	runMu          sync.Mutex
+	repo           *Repository
+	syncInterval   time.Duration
+	timeoutGrace   time.Duration
+	claimRetention time.Duration
+	leaseTTL       time.Duration
+	renewInterval  time.Duration
+	pollInterval   time.Duration
+	logger         *zap.SugaredLogger
+	mu             sync.RWMutex
+	ctx            context.Context
+	cancel         context.CancelFunc
 }
 
 type cronEntryInfo struct {
-	EntryID  cron.EntryID
-	CronExpr string
-	TimeOut  int
+	EntryID     cron.EntryID
+	ScheduleKey string
+	Task        *model.Hawthorn_task // 最近一次同步的任务配置，执行时使用
 }
 
-var noRecordExecution bool
+func newCronEntryInfo(entryID cron.EntryID, task *model.Hawthorn_task) cronEntryInfo {
+	return cronEntryInfo{
+		EntryID:     entryID,
+		ScheduleKey: scheduleKey(task),
+		Task:        task,
+	}
+}
+
+// sameSchedule 判断调度计划是否与数据库中一致，不一致时需要重新加入调度器
+func (e cronEntryInfo) sameSchedule(task *model.Hawthorn_task) bool {
+	return e.ScheduleKey == scheduleKey(task)
+}
+
+var (
+	noRecordExecution  bool
+	recordLockConflict bool
+	defaultManager     *TaskManager
+)
+
+// GetTaskManager 返回最近创建的任务管理器
+func GetTaskManager() *TaskManager {
+	return defaultManager
+}
 
 func NewTaskManager(taskCfg *config.TaskConfig) *TaskManager {
 	initLogger(taskCfg.LogLevel)
 	noRecordExecution = taskCfg.NotRecordTaskExecution
+	recordLockConflict = taskCfg.RecordLockConflict
 	lg := taskLogger.With(zap.String("traceID", "task-manager")).Sugar()
 	ctx, cancel := context.WithCancel(context.Background())
-	return &TaskManager{
-		nodeID:       taskCfg.NodeID,
-		cron:         cron.New(cron.WithSeconds()),
-		taskFuncs:    make(map[string]TaskFunc),
-		taskEntries:  make(map[int64]cronEntryInfo),
-		repo:         NewRepository(),
-		syncInterval: taskCfg.TaskSyncInterval,
-		logger:       lg,
-		ctx:          ctx,
-		cancel:       cancel,
-	}
-}
-
-func (m *TaskManager) RegisterTask(fn TaskFunc) error {
-	pc := reflect.ValueOf(fn).Pointer()
-	name := runtime.FuncForPC(pc).Name()
-
-	if _, exists := m.taskFuncs[name]; exists {
-		return fmt.Errorf("任务 [%s] 已注册", name)
+	defaultManager = &TaskManager{
+		nodeID:         taskCfg.NodeID,
+		cron:           cron.New(cron.WithSeconds()),
+		taskFuncs:      make(map[string]*taskHandler),
+		taskEntries:    make(map[int64]cronEntryInfo),
+		running:        make(map[string]*runningExecution),
+		repo:           NewRepository(),
+		syncInterval:   taskCfg.TaskSyncInterval,
+		timeoutGrace:   taskCfg.TimeoutGracePeriod,
+		claimRetention: taskCfg.ClaimRetention,
+		leaseTTL:       taskCfg.LockLeaseTTL,
+		renewInterval:  taskCfg.LockRenewInterval,
+		pollInterval:   taskCfg.PollInterval,
+		pool:           newWorkerPool(taskCfg.MaxConcurrentExecutions, taskCfg.GroupConcurrency),
+		queueTimeout:   taskCfg.QueueTimeout,
+		nodeExpiry:     taskCfg.NodeStaleTimeout,
+		logger:         lg,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
-
-	m.taskFuncs[name] = fn
-	m.logger.Debugf("注册任务成功:%s", name)
-	return nil
+	return defaultManager
 }
 
 func (m *TaskManager) Start() error {
+	m.startTime = time.Now().Truncate(time.Millisecond)
+	m.checkNodeID()
+	m.abandonOrphans()
 
-	if err := m.syncTasks(); err != nil {
+	if err := m.syncTasks(true); err != nil {
 		m.logger.Errorf("初始同步任务失败:%v", err)
 		return err
 	}
 	m.cron.Start()
+	m.heartbeat()
 
 	go m.startSyncLoop()
+	go m.startPollLoop()
 	m.logger.Debug("任务管理器启动成功")
 	return nil
 }
@@ -93,67 +157,154 @@ func (m *TaskManager) Start() error {
 func (m *TaskManager) Stop() {
 	m.cancel()
 	m.cron.Stop()
+	m.unregisterNode()
 	m.logger.Debug("任务管理器已停止")
 }
 
+// abandonOrphans 将本节点上次运行遗留的running执行记录标记为abandoned。
+// 节点重启时原执行的任务锁可能尚未过期，启动时跳过的记录在锁过期后及每小时清理时再次处理
+func (m *TaskManager) abandonOrphans() {
+	if noRecordExecution {
+		return
+	}
+	rows, err := m.repo.AbandonRunningExecutions(m.ctx, m.nodeID, m.startTime)
+	if err != nil {
+		m.logger.Errorf("处理遗留的running执行记录失败:%v", err)
+	} else if rows > 0 {
+		m.logger.Warnf("节点遗留%d条running执行记录，已标记为abandoned", rows)
+	}
+}
+
 func (m *TaskManager) startSyncLoop() {
 	ticker := time.NewTicker(m.syncInterval)
 	defer ticker.Stop()
+	cleanTicker := time.NewTicker(time.Hour)
+	defer cleanTicker.Stop()
+	orphanTimer := time.NewTimer(m.leaseTTL + m.renewInterval)
+	defer orphanTimer.Stop()
 
 	for {
 		select {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := m.syncTasks(); err != nil {
+			if err := m.syncTasks(false); err != nil {
 				m.logger.Errorf("同步任务失败:%v", err)
 			}
+			m.heartbeat()
+			if stats := m.pool.stats(); stats.Queued > 0 {
+				m.logger.Warnf("节点执行数已满，执行中%d，排队%d，平均排队%dms，排队超时%d",
+					stats.Running, stats.Queued, stats.AvgWait, stats.Timeouts)
+			}
+		case <-orphanTimer.C:
+			m.abandonOrphans()
+		case <-cleanTicker.C:
+			m.abandonOrphans()
+			rows, err := m.repo.DeleteClaimsBefore(m.ctx, m.claimRetention)
+			if err != nil {
+				m.logger.Errorf("清理任务抢占记录失败:%v", err)
+			} else {
+				m.logger.Debugf("清理任务抢占记录%d条", rows)
+			}
+			rows, err = m.repo.DeleteTriggersBefore(m.ctx, m.claimRetention)
+			if err != nil {
+				m.logger.Errorf("清理任务触发记录失败:%v", err)
+			} else {
+				m.logger.Debugf("清理任务触发记录%d条", rows)
+			}
+			rows, err = m.repo.DeleteShardsBefore(m.ctx, m.claimRetention)
+			if err != nil {
+				m.logger.Errorf("清理任务分片记录失败:%v", err)
+			} else {
+				m.logger.Debugf("清理任务分片记录%d条", rows)
+			}
+			rows, err = m.repo.DeleteCancelsBefore(m.ctx, m.claimRetention)
+			if err != nil {
+				m.logger.Errorf("清理取消请求失败:%v", err)
+			} else {
+				m.logger.Debugf("清理取消请求%d条", rows)
+			}
 		}
 	}
 }
 
-func (m *TaskManager) syncTasks() error {
+// syncTasks 同步任务配置到调度器，启动时的首次同步及数据库恢复后的同步会检查错过的触发，
+// 已被抢占过的触发在补偿时因抢占冲突跳过
+func (m *TaskManager) syncTasks(startup bool) error {
 	m.logger.Debug("开始同步任务配置")
+	resumed, err := m.repo.ResumeExpiredPauses(m.ctx)
+	if err != nil {
+		m.logger.Errorf("%v", err)
+	} else if len(resumed) > 0 {
+		m.logger.Infof("自动恢复暂停的任务%v", resumed)
+	}
 	tasks, err := m.repo.GetEnabledTasks(m.ctx)
 	if err != nil {
+		m.recheckMisfire.Store(true)
 		return fmt.Errorf("获取任务列表失败: %w", err)
 	}
+	recheck := m.recheckMisfire.Swap(false)
+	if recheck && !startup {
+		m.logger.Info("数据库访问已恢复，检查期间错过的触发")
+	}
+	m.loadMaintenanceWindows()
+	m.loadCalendars()
 
 	keepTaskIDs := make(map[int64]bool)
+	now := time.Now()
 
 	for _, task := range tasks {
+		if task.Paused {
+			continue
+		}
+		if !hasSchedule(task) {
+			continue // 只由依赖或手动触发
+		}
+		if task.EndAt != nil && !task.EndAt.After(now) {
+			m.logger.Debugf("任务[%s]已过失效时间%v", task.Name, task.EndAt)
+			continue
+		}
+		if _, exists := m.getHandler(task.HandlerName()); !exists {
+			m.logger.Warnf("任务[%s]的处理函数[%s]未注册", task.Name, task.HandlerName())
+			continue
+		}
 		keepTaskIDs[task.ID] = true
 
-		if entryInfo, exists := m.taskEntries[task.ID]; exists {
-			if entryInfo.CronExpr == task.CronExpr && entryInfo.TimeOut == task.Timeout {
+		entryInfo, exists := m.getEntry(task.ID)
+		if exists {
+			if entryInfo.sameSchedule(task) {
+				entryInfo.Task = task
+				m.setEntry(task.ID, entryInfo)
+				if recheck {
+					if sched, err := m.parseSchedule(task); err == nil {
+						m.checkMisfire(task, sched)
+					}
+				}
 				continue
 			}
 			m.cron.Remove(entryInfo.EntryID)
+			m.deleteEntry(task.ID)
 			m.logger.Debugf("移除变更的任务:%s", task.Name)
 		}
-		if _, exists := m.taskFuncs[task.Name]; !exists {
-			m.logger.Warnf("任务函数[%s]未注册", task.Name)
-			continue
-		}
 
-		entryID, err := m.addTaskToCron(task)
+		sched, err := m.parseSchedule(task)
 		if err != nil {
-			m.logger.Errorf("添加任务到调度器失败[%v-%v:%v]", task.ID, task.Name, err)
+			m.logger.Errorf("解析任务调度计划失败[%v-%v:%v]", task.ID, task.Name, err)
 			continue
 		}
-		m.taskEntries[task.ID] = cronEntryInfo{
-			EntryID:  entryID,
-			CronExpr: task.CronExpr,
-			TimeOut:  task.Timeout,
+		entryID := m.addTaskToCron(task.ID, sched)
+		m.setEntry(task.ID, newCronEntryInfo(entryID, task))
+		if startup || recheck {
+			m.checkMisfire(task, sched)
 		}
 
-		m.logger.Debugf("添加/更新任务调度[%v-%v-%v]", task.ID, task.Name, task.CronExpr)
+		m.logger.Debugf("添加/更新任务调度[%v-%v-%v]", task.ID, task.Name, scheduleKey(task))
 	}
 
-	for taskID, entryInfo := range m.taskEntries {
+	for taskID, entryInfo := range m.entries() {
 		if !keepTaskIDs[taskID] {
 			m.cron.Remove(entryInfo.EntryID)
-			delete(m.taskEntries, taskID)
+			m.deleteEntry(taskID)
 			m.logger.Debugf("移除已删除的任务[%v]", taskID)
 		}
 	}
@@ -162,25 +313,78 @@ func (m *TaskManager) syncTasks() error {
 	return nil
 }
 
-func (m *TaskManager) addTaskToCron(task *model.Hawthorn_task) (cron.EntryID, error) {
+func (m *TaskManager) getEntry(taskID int64) (cronEntryInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, exists := m.taskEntries[taskID]
+	return entry, exists
+}
+
+func (m *TaskManager) setEntry(taskID int64, entry cronEntryInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.taskEntries[taskID] = entry
+}
+
+func (m *TaskManager) deleteEntry(taskID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.taskEntries, taskID)
+}
+
+func (m *TaskManager) entries() map[int64]cronEntryInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ret := make(map[int64]cronEntryInfo, len(m.taskEntries))
+	for id, entry := range m.taskEntries {
+		ret[id] = entry
+	}
+	return ret
+}
+
+func (m *TaskManager) addTaskToCron(taskID int64, sched cron.Schedule) cron.EntryID {
+	fs := newFiredSchedule(sched)
 	job := func() {
+		scheduledAt := fs.fired()
 		defer func() {
 			err := recover()
 			if err != nil {
 				m.logger.Error("任务执行框架异常：", zap.Error(fmt.Errorf("%v", err)))
 			}
 		}()
-		m.executeTask(task)
+		entry, exists := m.getEntry(taskID)
+		if !exists {
+			return
+		}
+		if d := jitterDelay(entry.Task); d > 0 {
+			if sleepContext(m.ctx, d) != nil {
+				return
+			}
+			// 延迟期间任务配置可能已变更或移除
+			if entry, exists = m.getEntry(taskID); !exists {
+				return
+			}
+		}
+		m.executeTask(entry.Task, taskFire{scheduledAt: scheduledAt, triggerType: TriggerCron})
 	}
 
-	return m.cron.AddFunc(task.CronExpr, job)
+	return m.cron.Schedule(fs, cron.FuncJob(job))
 }
 
 var (
-	stateFiled     = "failed"
-	stateSuccess   = "success"
-	lockTaskFailed = "任务抢占失败"
-	noFunc         = "任务函数未注册"
+	stateFiled        = "failed"
+	stateSuccess      = "success"
+	stateTimeout      = "timeout"
+	stateSkipped      = "skipped"
+	stateLockConflict = "lock_conflict"
+	stateRunning      = "running"
+	stateAbandoned    = "abandoned"
+	stateCancelled    = "cancelled"
+	lockTaskFailed    = "任务抢占失败"
+	lockConflict      = "本次触发已被其他节点抢占"
+	noFunc            = "任务函数未注册"
+	paramsInvalid     = "任务参数错误"
+	taskBusy          = "任务正在执行中，本次触发未执行"
 )
 
 func createContext() (traceID string, ctx context.Context, lg *zap.Logger) {
@@ -192,83 +396,257 @@ func createContext() (traceID string, ctx context.Context, lg *zap.Logger) {
 	l := taskLogger.With(zap.String("traceID", id))
 	return id, c, l
 }
-func (m *TaskManager) executeTask(task *model.Hawthorn_task) {
+func (m *TaskManager) executeTask(task *model.Hawthorn_task, fire taskFire) {
 	traceID, ctx, lg := createContext()
-	nw := time.Now()
-	now := nw.Truncate(time.Millisecond)
-	expiredAt := nw.Add(time.Duration(task.Timeout) * time.Second).Truncate(time.Millisecond)
+	// 计划时间可能处于任务时区，统一为节点时区后再入库
+	fire.scheduledAt = fire.scheduledAt.In(time.Local).Truncate(time.Millisecond)
+	now := time.Now().Truncate(time.Millisecond)
 	execution := &model.Hawthorn_task_execution{
 		TaskID:      task.ID,
 		NodeID:      m.nodeID,
 		TraceID:     traceID,
+		ScheduledAt: &fire.scheduledAt,
+		TriggerType: fire.triggerType,
+		TriggerBy:   fire.triggerBy,
 		StartTime:   now,
 		CreatedDate: now,
 	}
+	// 先取得执行名额再抢占，执行数已满的节点把触发留给其他节点
+	release, queueWait, queueErr := m.pool.acquire(m.ctx, task.TaskGroup, task.Priority, m.queueTimeout)
+	if queueErr == nil {
+		defer release()
+	} else if !errors.Is(queueErr, errQueueTimeout) {
+		lg.Info("任务管理器已停止，放弃排队中的任务", zap.Int64("taskID", task.ID))
+		return
+	} else if fire.triggerType == TriggerCron || fire.triggerType == TriggerMisfire {
+		lg.Warn("节点执行数已满，不抢占本次触发，留给其他节点", zap.Int64("taskID", task.ID),
+			zap.Time("scheduledAt", fire.scheduledAt), zap.Duration("queueWait", queueWait))
+		return
+	}
+	if queueWait > time.Second {
+		lg.Info("任务排队等待执行", zap.Int64("taskID", task.ID), zap.Duration("queueWait", queueWait), zap.Error(queueErr))
+	}
+
 	var finalErr error
+	var lock *taskLock
+	stopLease := func() {}
+	taskCtx, cancel := m.taskContext(ctx, task)
+	defer cancel(nil)
 
 	defer func() {
+		stopLease()
+		if cause := context.Cause(taskCtx); finalErr != nil && cause != nil && !errors.Is(finalErr, cause) {
+			finalErr = fmt.Errorf("%w（%v）", finalErr, cause)
+		}
 		err := recover()
 		if err != nil {
-			lg.Sugar().Errorw("未知异常:%v", err)
+			lg.Sugar().Errorf("未知异常:%v", err)
 			execution.Error = fmt.Sprintf("未知异常：%v", err)
 			execution.Status = stateFiled
+		} else if finalErr != nil && isCancelled(taskCtx) {
+			lg.Sugar().Warnf("任务已取消:%v", finalErr)
+			execution.Error = fmt.Sprintf("任务取消：%v", finalErr)
+			execution.Status = stateCancelled
+		} else if finalErr != nil && isTimeout(taskCtx) {
+			lg.Sugar().Errorf("任务超时:%v", finalErr)
+			execution.Error = fmt.Sprintf("任务超时：%v", finalErr)
+			execution.Status = stateTimeout
 		} else if finalErr != nil {
-			lg.Sugar().Errorw("任务失败:%v", finalErr)
+			lg.Sugar().Errorf("任务失败:%v", finalErr)
 			execution.Error = fmt.Sprintf("任务失败：%v", finalErr)
 			execution.Status = stateFiled
 		} else if execution.Status == "" {
 			return
 		}
 
-		err2 := m.repo.ReleaseLockTask(ctx, task.ID, now, expiredAt, lg)
-		if err2 != nil {
-			execution.Error = execution.Error + "释放锁失败"
-			lg.Sugar().Errorw("释放锁失败：%d,%w", task.ID, err2)
+		if lock != nil {
+			err2 := m.repo.ReleaseLockTask(ctx, lock, lg)
+			if err2 != nil {
+				execution.Error = execution.Error + "释放锁失败"
+				lg.Sugar().Errorf("释放锁失败：%d,%v", task.ID, err2)
+			}
 		}
 		if !noRecordExecution {
 			end := time.Now().Truncate(time.Millisecond)
 			execution.EndTime = &end
-			if err := m.repo.CreateExecution(ctx, execution); err != nil {
+			if execution.ID != 0 {
+				if err := m.repo.FinishExecution(ctx, execution); err != nil {
+					panic(fmt.Errorf("更新执行记录失败: %v", err))
+				}
+			} else if err := m.repo.CreateExecution(ctx, execution); err != nil {
 				panic(fmt.Errorf("登记执行记录失败: %v", err))
 			}
+			m.triggerDownstream(ctx, task, execution, lg)
 		}
 	}()
 
-	lockErr := m.repo.TryLockTask(ctx, task.ID, now, expiredAt)
+	if fire.triggerType != TriggerManual {
+		claimed, claimErr := m.repo.ClaimTask(ctx, &model.Hawthorn_task_claim{
+			TaskID:      task.ID,
+			ScheduledAt: fire.scheduledAt,
+			NodeID:      m.nodeID,
+			TraceID:     traceID,
+		})
+		if claimErr != nil {
+			m.recheckMisfire.Store(true)
+			execution.Status = stateFiled
+			execution.Error = lockTaskFailed
+			lg.Sugar().Errorf("任务%d-%s抢占失败:%v", task.ID, task.Name, claimErr)
+			return
+		}
+		if !claimed {
+			lg.Debug("本次触发已被其他节点抢占", zap.Int64("taskID", task.ID), zap.Time("scheduledAt", fire.scheduledAt))
+			if recordLockConflict {
+				execution.Status = stateLockConflict
+				execution.Error = lockConflict
+			}
+			return
+		}
+	}
+
+	// 一次性触发已由本节点领取，其他节点无法再执行，记为跳过
+	if queueErr != nil {
+		lg.Warn("节点执行数已满，放弃本次执行", zap.Int64("taskID", task.ID), zap.Error(queueErr))
+		execution.Status = stateSkipped
+		execution.Error = queueErr.Error()
+		return
+	}
+
+	if reason := m.skipReason(ctx, task, fire); reason != "" {
+		lg.Info("任务跳过", zap.Int64("taskID", task.ID), zap.String("reason", reason))
+		execution.Status = stateSkipped
+		execution.Error = reason
+		return
+	}
+
+	var lockErr error
+	lock, lockErr = m.acquireLock(ctx, task, lockOwner(m.nodeID, traceID), lg)
 	if lockErr != nil {
+		if errors.Is(lockErr, ErrTaskPaused) || errors.Is(lockErr, ErrTaskNotFound) {
+			lg.Info("任务已暂停或停用，放弃本次执行", zap.Int64("taskID", task.ID), zap.Error(lockErr))
+			execution.Status = stateSkipped
+			execution.Error = lockErr.Error()
+			return
+		}
 		if errors.Is(lockErr, gorm.ErrRecordNotFound) {
+			lg.Info("任务上次执行尚未结束，放弃本次执行", zap.Int64("taskID", task.ID))
+			execution.Status = stateSkipped
+			execution.Error = taskBusy
 			return
 		}
 		execution.Status = stateFiled
 		execution.Error = lockTaskFailed
-		lg.Sugar().Errorw("任务%d-%s抢占失败:%w", task.ID, task.Name, lockErr)
+		lg.Sugar().Errorf("任务%d-%s抢占失败:%v", task.ID, task.Name, lockErr)
 		return
 	}
-	m.mu.RLock()
-	taskFunc, exists := m.taskFuncs[task.Name]
-	m.mu.RUnlock()
-
+	if lock != nil {
+		stopLease = m.keepLease(taskCtx, cancel, lock, lg)
+	}
+	m.registerRunning(&runningExecution{TaskID: task.ID, TraceID: traceID, StartTime: now, cancel: cancel})
+	defer m.unregisterRunning(traceID)
+	if !noRecordExecution {
+		execution.Status = stateRunning
+		if err := m.repo.CreateExecution(ctx, execution); err != nil {
+			execution.ID = 0
+			lg.Error("登记running执行记录失败", zap.Error(err))
+		}
+	}
+	lg.Info("任务开始执行",
+		zap.Int64("taskID", task.ID),
+		zap.String("taskName", task.Name),
+		zap.Time("scheduledAt", fire.scheduledAt),
+		zap.Duration("latency", now.Sub(fire.scheduledAt)),
+		zap.Duration("queueWait", queueWait),
+		zap.String("triggerType", fire.triggerType))
+	handler, exists := m.getHandler(task.HandlerName())
 	if !exists {
 		execution.Status = stateFiled
 		execution.Error = noFunc
 		return
 	}
+	params := task.Params
+	if len(fire.params) > 0 {
+		params = fire.params
+	}
+	if err := handler.checkParams(params); err != nil {
+		execution.Status = stateFiled
+		execution.Error = fmt.Sprintf("%s：%v", paramsInvalid, err)
+		return
+	}
 
+	if task.ShardCount > 1 {
+		// 父执行只等待分片结束，让出执行名额给本节点抢占到的分片
+		release()
+		if finalErr = m.runShards(taskCtx, task, traceID, fire, params, lg); finalErr == nil {
+			execution.Status = stateSuccess
+		}
+		return
+	}
+
+	var upstreamResults map[string]json.RawMessage
+	if fire.triggerType == TriggerDependency {
+		var err error
+		if upstreamResults, err = m.repo.GetUpstreamResults(ctx, task.ID, fire.scheduledAt); err != nil {
+			lg.Error("查询上游任务结果失败", zap.Error(err))
+		}
+	}
+	result := &taskResult{}
+	defer func() {
+		execution.Result = result.get()
+	}()
+
+	var skip string
+	execution.Attempts, skip, finalErr = m.runAttempts(taskCtx, task, handler, TaskParams{
+		TaskID:      task.ID,
+		TaskName:    task.Name,
+		Params:      params,
+		ScheduledAt: fire.scheduledAt,
+		Misfire:     fire.triggerType == TriggerMisfire,
+		TriggerType: fire.triggerType,
+
+		UpstreamResults: upstreamResults,
+		result:          result,
+	}, lg)
+	if finalErr == nil {
+		execution.Status = stateSuccess
+		if skip != "" {
+			execution.Status = stateSkipped
+			execution.Error = skip
+		}
+	}
+	return
+}
+
+// runAttempts 按任务的重试策略执行任务函数，任务函数主动跳过时返回跳过原因，失败时返回最后一次的错误
+func (m *TaskManager) runAttempts(ctx context.Context, task *model.Hawthorn_task, handler *taskHandler, params TaskParams, lg *zap.Logger) (attempts []model.TaskAttempt, skip string, err error) {
+	policy := newRetryPolicy(task)
 	for i := 0; i <= task.RetryCount; i++ {
-		finalErr = taskFunc(ctx, TaskParams{
-			TaskID:     task.ID,
-			TaskName:   task.Name,
-			RetryCount: i,
-		})
+		start := time.Now()
+		params.RetryCount = i
+		err = runTaskFunc(ctx, handler, params, m.timeoutGrace, lg)
+		attempts = append(attempts, newAttempt(i, start, err))
 
-		if finalErr == nil {
-			execution.Status = stateSuccess
-			break
+		if err == nil {
+			return attempts, "", nil
+		}
+		if reason, ok := SkipReason(err); ok {
+			lg.Sugar().Infof("任务跳过:%s", reason)
+			return attempts, reason, nil
+		}
+		if ctx.Err() != nil || IsPermanent(err) {
+			return attempts, "", err
 		}
 
 		if i < task.RetryCount {
-			time.Sleep(1 * time.Second)
+			delay, ok := retryDelay(err)
+			if !ok {
+				delay = policy.delay(i + 1)
+			}
+			lg.Sugar().Warnf("任务第%d次执行失败，%v后重试:%v", i+1, delay, err)
+			if err2 := sleepContext(ctx, delay); err2 != nil {
+				return attempts, "", fmt.Errorf("%w，重试等待中断：%v", err, err2)
+			}
 		}
 	}
-	return
+	return attempts, "", err
 }
@@ -0,0 +1,137 @@
+package cron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+	"gorm.io/gorm"
+)
+
+const (
+	triggerPending = "pending"
+	triggerClaimed = "claimed"
+)
+
+// 每次轮询最多抢占的触发数
+const triggerBatchSize = 10
+
+var (
+	ErrTaskNotFound = errors.New("任务不存在或未启用")
+	ErrTaskPaused   = errors.New("任务已暂停")
+)
+
+// Trigger 立即执行一次任务，params非空时覆盖任务配置的参数。
+// 触发登记后由注册了该任务处理函数的某一个节点抢占执行，ctx中的user_id记为触发人。
+func (m *TaskManager) Trigger(ctx context.Context, taskID int64, params json.RawMessage) (*model.Hawthorn_task_trigger, error) {
+	task, err := m.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
+	}
+	if !task.Enabled {
+		return nil, ErrTaskNotFound
+	}
+	if task.Paused {
+		return nil, ErrTaskPaused
+	}
+	if bytes.Equal(bytes.TrimSpace(params), []byte("null")) {
+		params = nil
+	}
+	if err := validParams(params); err != nil {
+		return nil, err
+	}
+	if handler, ok := m.getHandler(task.HandlerName()); ok && len(params) > 0 {
+		if err := handler.checkParams(params); err != nil {
+			return nil, err
+		}
+	}
+
+	operator := contextString(ctx, "user_id")
+	// 计划时间取节点时钟，不依赖数据库会话时区
+	now := time.Now().Truncate(time.Millisecond)
+	trigger := &model.Hawthorn_task_trigger{
+		TaskID:      taskID,
+		TriggerType: TriggerManual,
+		TriggerBy:   operator,
+		Params:      params,
+		ScheduledAt: &now,
+		Status:      triggerPending,
+	}
+	if err := m.repo.CreateTrigger(ctx, trigger); err != nil {
+		return nil, fmt.Errorf("登记任务触发失败: %w", err)
+	}
+	if err := m.repo.CreateOperation(ctx, &model.Hawthorn_task_operation{
+		TaskID:   taskID,
+		Action:   ActionTrigger,
+		Operator: operator,
+		Detail:   string(params),
+		TraceID:  contextString(ctx, "traceID"),
+	}); err != nil {
+		m.logger.Errorf("登记触发操作记录失败:%v", err)
+	}
+	return trigger, nil
+}
+
+func (m *TaskManager) handlerNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.taskFuncs))
+	for name := range m.taskFuncs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// pollTriggers 抢占并执行待执行的一次性触发
+func (m *TaskManager) pollTriggers() {
+	handlers := m.handlerNames()
+	if len(handlers) == 0 {
+		return
+	}
+	// 执行数已满时不再抢占，留给其他节点
+	limit := triggerBatchSize
+	if n := m.pool.available(); n >= 0 {
+		limit = min(limit, n)
+	}
+	if limit == 0 {
+		return
+	}
+	triggers, err := m.repo.ClaimTriggers(m.ctx, m.nodeID, handlers, limit)
+	if err != nil {
+		m.logger.Errorf("%v", err)
+		return
+	}
+	for _, trigger := range triggers {
+		task, err := m.repo.GetTask(m.ctx, trigger.TaskID)
+		if err != nil {
+			m.logger.Errorf("查询触发的任务[%v]失败:%v", trigger.TaskID, err)
+			continue
+		}
+		fire := taskFire{
+			triggerType: trigger.TriggerType,
+			triggerBy:   trigger.TriggerBy,
+			params:      trigger.Params,
+		}
+		if trigger.ScheduledAt != nil {
+			fire.scheduledAt = *trigger.ScheduledAt
+		} else if trigger.CreatedAt != nil {
+			fire.scheduledAt = *trigger.CreatedAt
+		}
+		m.logger.Infof("执行%s触发的任务[%v-%v]", trigger.TriggerBy, task.ID, task.Name)
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					m.logger.Errorf("任务触发执行异常:%v", err)
+				}
+			}()
+			m.executeTask(task, fire)
+		}()
+	}
+}
@@ -0,0 +1,29 @@
+package task
+
+import (
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+type DagQuery struct {
+	TaskID int64 `json:"task_id"`
+	Size   int   `json:"size"` // 最近的运行次数
+}
+
+type DagNode struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// DagRun 同一计划触发时间下DAG内各任务的执行
+type DagRun struct {
+	ScheduledAt time.Time      `json:"scheduled_at"`
+	Executions  []ExecutionDto `json:"executions"`
+}
+
+type DagDto struct {
+	Tasks []DagNode                        `json:"tasks"`
+	Edges []model.Hawthorn_task_dependency `json:"edges"`
+	Runs  []DagRun                         `json:"runs"`
+}
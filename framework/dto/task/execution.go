@@ -0,0 +1,23 @@
+package task
+
+import "github.com/hawthorntrees/cronframework/framework/model"
+
+type ExecutionQuery struct {
+	TaskID  int64  `json:"task_id"`
+	Status  string `json:"status"`
+	TraceID string `json:"trace_id"`
+	Page    int    `json:"page"`
+	Size    int    `json:"size"`
+}
+
+type ExecutionDto struct {
+	model.Hawthorn_task_execution
+	Latency int64 `json:"latency"` // 调度延迟，毫秒
+}
+
+func NewExecutionDto(execution model.Hawthorn_task_execution) ExecutionDto {
+	return ExecutionDto{
+		Hawthorn_task_execution: execution,
+		Latency:                 execution.Latency().Milliseconds(),
+	}
+}
@@ -0,0 +1,31 @@
+package task
+
+import (
+	"time"
+
+	"github.com/hawthorntrees/cronframework/framework/model"
+)
+
+type TaskQuery struct {
+	Name   string `json:"name"`
+	Paused *bool  `json:"paused"`
+	Page   int    `json:"page"`
+	Size   int    `json:"size"`
+}
+
+type TaskDto struct {
+	model.Hawthorn_task
+	NextRunTimes  []time.Time `json:"next_run_times"`           // 后续计划触发时间，处于任务时区
+	ScheduleError string      `json:"schedule_error,omitempty"` // cron表达式或时区无效时的原因
+}
+
+func NewTaskDto(t model.Hawthorn_task, nextRunTimes []time.Time, err error) TaskDto {
+	dto := TaskDto{
+		Hawthorn_task: t,
+		NextRunTimes:  nextRunTimes,
+	}
+	if err != nil {
+		dto.ScheduleError = err.Error()
+	}
+	return dto
+}
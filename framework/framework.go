@@ -73,8 +73,12 @@ func New(filepath string) (*Framework, error) {
 
 }
 
-func (f *Framework) RegisterTask(fn cron.TaskFunc) error {
-	return f.taskManager.RegisterTask(fn)
+func (f *Framework) RegisterTask(fn cron.TaskFunc, opts ...cron.TaskOption) error {
+	return f.taskManager.RegisterTask(fn, opts...)
+}
+
+func (f *Framework) RegisterTaskNamed(name string, fn cron.TaskFunc, opts ...cron.TaskOption) error {
+	return f.taskManager.RegisterTaskNamed(name, fn, opts...)
 }
 
 func (f *Framework) Router() *gin.RouterGroup {
@@ -141,7 +145,22 @@ func (f *Framework) gracefulShutdown() {
 
 func (f *Framework) AutoMigrate() error {
 	db := dbs.GetDB()
-	err := db.AutoMigrate(&model.Hawthorn_task{}, &model.Hawthorn_task_execution{})
+	err := db.AutoMigrate(
+		&model.Hawthorn_task{},
+		&model.Hawthorn_task_execution{},
+		&model.Hawthorn_task_claim{},
+		&model.Hawthorn_task_operation{},
+		&model.Hawthorn_task_trigger{},
+		&model.Hawthorn_cluster_control{},
+		&model.Hawthorn_maintenance_window{},
+		&model.Hawthorn_calendar{},
+		&model.Hawthorn_calendar_day{},
+		&model.Hawthorn_task_dependency{},
+		&model.Hawthorn_task_slot{},
+		&model.Hawthorn_task_shard{},
+		&model.Hawthorn_node{},
+		&model.Hawthorn_task_cancel{},
+	)
 	if err != nil {
 		f.log.Warn("数据迁移失败")
 		return err
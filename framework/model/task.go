@@ -1,40 +1,276 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type Hawthorn_task struct {
+	ID                int64           `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	Name              string          `gorm:"column:name;type:varchar(100);not null;uniqueIndex" json:"name"`
+	Handler           string          `gorm:"column:handler;type:varchar(200)" json:"handler"`                          // 任务处理函数注册名，为空时取name
+	TaskGroup         string          `gorm:"column:task_group;type:varchar(50);not null;default:''" json:"task_group"` // 任务分组，用于维护窗口等按组配置
+	Priority          int             `gorm:"column:priority;type:int;not null;default:0" json:"priority"`              // 节点执行数已满时优先执行数值大的任务
+	Description       string          `gorm:"column:description;type:text" json:"description"`
+	CronExpr          string          `gorm:"column:cron_expr;type:varchar(100);not null" json:"cron_expr"`                         // 为空时只由依赖或手动触发
+	Schedules         []string        `gorm:"column:schedules;type:jsonb;serializer:json" json:"schedules"`                         // 额外的cron表达式，与cron_expr取并集
+	StartAt           *time.Time      `gorm:"column:start_at;type:timestamp(3)" json:"start_at"`                                    // 生效时间，为空时不限制
+	EndAt             *time.Time      `gorm:"column:end_at;type:timestamp(3)" json:"end_at"`                                        // 失效时间，到期后不再调度
+	Timezone          string          `gorm:"column:timezone;type:varchar(50)" json:"timezone"`                                     // IANA时区名，为空时使用节点时区
+	JitterMode        string          `gorm:"column:jitter_mode;type:varchar(20)" json:"jitter_mode"`                               // random, fixed，为空时不延迟
+	JitterWindow      int             `gorm:"column:jitter_window;type:int;not null;default:0" json:"jitter_window"`                // 秒，触发后在该窗口内延迟执行
+	Calendar          string          `gorm:"column:calendar;type:varchar(100)" json:"calendar"`                                    // 工作日历名称，为空时不限制
+	CalendarPolicy    string          `gorm:"column:calendar_policy;type:varchar(20);not null;default:skip" json:"calendar_policy"` // 非工作日的处理：skip, shift, run
+	Enabled           bool            `gorm:"column:enabled;type:bool;not null;default:true" json:"enabled"`
+	Paused            bool            `gorm:"column:paused;type:bool;not null;default:false" json:"paused"` // 暂停调度，保留启用状态
+	PausedAt          *time.Time      `gorm:"column:paused_at;type:timestamp(3)" json:"paused_at"`
+	PausedBy          string          `gorm:"column:paused_by;type:varchar(50)" json:"paused_by"`
+	PauseReason       string          `gorm:"column:pause_reason;type:varchar(200)" json:"pause_reason"`
+	ResumeAt          *time.Time      `gorm:"column:resume_at;type:timestamp(3)" json:"resume_at"`                                          // 到期自动恢复
+	Timeout           int             `gorm:"column:timeout;type:int;not null;default:300" json:"timeout"`                                  // 秒
+	ConcurrencyPolicy string          `gorm:"column:concurrency_policy;type:varchar(20);not null;default:forbid" json:"concurrency_policy"` // forbid, allow, replace
+	MaxConcurrency    int             `gorm:"column:max_concurrency;type:int;not null;default:1" json:"max_concurrency"`                    // allow时集群内最多同时执行数，0为不限制
+	ShardCount        int             `gorm:"column:shard_count;type:int;not null;default:0" json:"shard_count"`                            // 大于1时每次触发拆分为多个分片，由各节点分别执行
+	RetryCount        int             `gorm:"column:retry_count;type:int;not null;default:0" json:"retry_count"`
+	RetryPolicy       string          `gorm:"column:retry_policy;type:varchar(20);not null;default:fixed" json:"retry_policy"`      // fixed, linear, exponential, exponential_jitter
+	RetryInterval     int             `gorm:"column:retry_interval;type:int;not null;default:1000" json:"retry_interval"`           // 毫秒
+	RetryMaxInterval  int             `gorm:"column:retry_max_interval;type:int;not null;default:60000" json:"retry_max_interval"`  // 毫秒
+	MisfirePolicy     string          `gorm:"column:misfire_policy;type:varchar(20);not null;default:ignore" json:"misfire_policy"` // ignore, fire_once, fire_all
+	MisfireLimit      int             `gorm:"column:misfire_limit;type:int;not null;default:10" json:"misfire_limit"`               // fire_all最多补偿次数
+	Params            json.RawMessage `gorm:"column:params;type:jsonb" json:"params"`                                               // 任务参数
+	LockedBy          *string         `gorm:"column:locked_by;type:varchar(100)" json:"locked_by"`
+	LockedAt          *time.Time      `gorm:"column:locked_at;type:timestamp(3)" json:"locked_at"`
+	ExpiredAt         *time.Time      `gorm:"column:expired_at;type:timestamp(3)" json:"expired_at"`
+	CreatedAt         *time.Time      `gorm:"column:created_at;type:timestamp(3)" json:"created_at"`
+	UpdatedAt         *time.Time      `gorm:"column:updated_at;type:timestamp(3)" json:"updated_at"`
+}
+
+func (Hawthorn_task) TableName() string {
+	return "hawthorn_task"
+}
+
+func (t *Hawthorn_task) HandlerName() string {
+	if t.Handler != "" {
+		return t.Handler
+	}
+	return t.Name
+}
+
+type Hawthorn_task_execution struct {
+	ID          int64           `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	CreatedDate time.Time       `gorm:"column:created_date;type:date;not null;primaryKey" json:"created_date"`
+	TaskID      int64           `gorm:"column:task_id;type:bigint;not null" json:"task_id"`
+	NodeID      string          `gorm:"column:node_id;type:varchar(100);not null" json:"node_id"`
+	Status      string          `gorm:"column:status;type:varchar(20);not null" json:"status"`     // running, success, failed, timeout, cancelled, skipped, lock_conflict, abandoned
+	ScheduledAt *time.Time      `gorm:"column:scheduled_at;type:timestamp(3)" json:"scheduled_at"` // 计划触发时间
+	StartTime   time.Time       `gorm:"column:start_time;type:timestamp(3);not null" json:"start_time"`
+	EndTime     *time.Time      `gorm:"column:end_time;type:timestamp(3)" json:"end_time"`
+	Error       string          `gorm:"column:error;type:text" json:"error"`
+	TraceID     string          `gorm:"column:trace_id;type:varchar(64);index" json:"trace_id"`     // 全流程追踪号
+	Attempts    []TaskAttempt   `gorm:"column:attempts;type:jsonb;serializer:json" json:"attempts"` // 每次尝试的执行情况
+	CancelAt    *time.Time      `gorm:"column:cancel_at;type:timestamp(3)" json:"cancel_at"`        // 请求取消的时间
+	CancelBy    string          `gorm:"column:cancel_by;type:varchar(50)" json:"cancel_by"`         // 请求取消的用户
+	TriggerType string          `gorm:"column:trigger_type;type:varchar(20)" json:"trigger_type"`   // cron, misfire, manual, dependency
+	TriggerBy   string          `gorm:"column:trigger_by;type:varchar(50)" json:"trigger_by"`       // 手动触发的用户或触发依赖的上游任务
+	Result      json.RawMessage `gorm:"column:result;type:jsonb" json:"result"`                     // 任务函数保存的结果，传给下游依赖任务
+}
+
+func (Hawthorn_task_execution) TableName() string {
+	return "hawthorn_task_execution"
+}
+
+// Latency 调度延迟，开始执行时间与计划触发时间之差
+func (e *Hawthorn_task_execution) Latency() time.Duration {
+	if e.ScheduledAt == nil || e.ScheduledAt.IsZero() {
+		return 0
+	}
+	return e.StartTime.Sub(*e.ScheduledAt)
+}
+
+type TaskAttempt struct {
+	Attempt   int       `json:"attempt"` // 从0开始，0为首次执行
+	StartTime time.Time `json:"start_time"`
+	Duration  int64     `json:"duration"` // 毫秒
+	Error     string    `json:"error,omitempty"`
+}
+
+// Hawthorn_task_claim 每个计划触发时间只允许一个节点抢占执行
+type Hawthorn_task_claim struct {
+	TaskID      int64      `gorm:"column:task_id;type:bigint;primaryKey;autoIncrement:false" json:"task_id"`
+	ScheduledAt time.Time  `gorm:"column:scheduled_at;type:timestamp(3);primaryKey" json:"scheduled_at"`
+	NodeID      string     `gorm:"column:node_id;type:varchar(100);not null" json:"node_id"`
+	TraceID     string     `gorm:"column:trace_id;type:varchar(64)" json:"trace_id"`
+	ClaimedAt   *time.Time `gorm:"column:claimed_at;type:timestamp(3);not null;default:now();index" json:"claimed_at"` // 数据库时间
+}
+
+func (Hawthorn_task_claim) TableName() string {
+	return "hawthorn_task_claim"
+}
+
+// Hawthorn_task_operation 任务的人工操作记录
+type Hawthorn_task_operation struct {
+	ID        int64      `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	TaskID    int64      `gorm:"column:task_id;type:bigint;not null;index" json:"task_id"`
+	Action    string     `gorm:"column:action;type:varchar(50);not null" json:"action"`
+	Operator  string     `gorm:"column:operator;type:varchar(50)" json:"operator"`
+	Detail    string     `gorm:"column:detail;type:text" json:"detail"`
+	TraceID   string     `gorm:"column:trace_id;type:varchar(64)" json:"trace_id"`
+	CreatedAt *time.Time `gorm:"column:created_at;type:timestamp(3);not null;default:now()" json:"created_at"`
+}
+
+func (Hawthorn_task_operation) TableName() string {
+	return "hawthorn_task_operation"
+}
+
+// Hawthorn_task_trigger 待执行的一次性触发，由一个节点抢占后执行
+type Hawthorn_task_trigger struct {
+	ID          int64           `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	TaskID      int64           `gorm:"column:task_id;type:bigint;not null" json:"task_id"`
+	TriggerType string          `gorm:"column:trigger_type;type:varchar(20);not null" json:"trigger_type"`
+	TriggerBy   string          `gorm:"column:trigger_by;type:varchar(50)" json:"trigger_by"`
+	Params      json.RawMessage `gorm:"column:params;type:jsonb" json:"params"`                      // 覆盖任务配置的参数
+	ScheduledAt *time.Time      `gorm:"column:scheduled_at;type:timestamp(3)" json:"scheduled_at"`   // 计划触发时间，依赖触发时为上游的计划触发时间
+	Status      string          `gorm:"column:status;type:varchar(20);not null;index" json:"status"` // pending, claimed
+	NodeID      string          `gorm:"column:node_id;type:varchar(100)" json:"node_id"`
+	CreatedAt   *time.Time      `gorm:"column:created_at;type:timestamp(3);not null;default:now()" json:"created_at"`
+	ClaimedAt   *time.Time      `gorm:"column:claimed_at;type:timestamp(3)" json:"claimed_at"`
+}
+
+func (Hawthorn_task_trigger) TableName() string {
+	return "hawthorn_task_trigger"
+}
+
+// Hawthorn_cluster_control 集群级调度开关，只有一行记录
+type Hawthorn_cluster_control struct {
+	ID        int        `gorm:"column:id;type:int;primaryKey;autoIncrement:false" json:"id"`
+	Suspended bool       `gorm:"column:suspended;type:bool;not null;default:false" json:"suspended"` // 暂停全部任务调度
+	Reason    string     `gorm:"column:reason;type:varchar(200)" json:"reason"`
+	UpdatedBy string     `gorm:"column:updated_by;type:varchar(50)" json:"updated_by"`
+	UpdatedAt *time.Time `gorm:"column:updated_at;type:timestamp(3)" json:"updated_at"`
+}
+
+func (Hawthorn_cluster_control) TableName() string {
+	return "hawthorn_cluster_control"
+}
+
+// Hawthorn_maintenance_window 周期性维护窗口，窗口内的触发记为skipped
+type Hawthorn_maintenance_window struct {
+	ID        int64  `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	Name      string `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	TaskGroup string `gorm:"column:task_group;type:varchar(50);not null;default:''" json:"task_group"` // 为空时对所有分组生效
+	StartTime string `gorm:"column:start_time;type:varchar(5);not null" json:"start_time"`             // HH:MM
+	EndTime   string `gorm:"column:end_time;type:varchar(5);not null" json:"end_time"`                 // HH:MM，小于开始时间表示跨天
+	Weekdays  string `gorm:"column:weekdays;type:varchar(20)" json:"weekdays"`                         // 逗号分隔，0为周日，为空表示每天
+	Timezone  string `gorm:"column:timezone;type:varchar(50)" json:"timezone"`                         // 为空时使用节点时区
+	Enabled   bool   `gorm:"column:enabled;type:bool;not null;default:true" json:"enabled"`
+}
+
+func (Hawthorn_maintenance_window) TableName() string {
+	return "hawthorn_maintenance_window"
+}
+
+// Hawthorn_calendar 工作日历，未配置例外日期时按休息日判断
+type Hawthorn_calendar struct {
 	ID          int64      `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
 	Name        string     `gorm:"column:name;type:varchar(100);not null;uniqueIndex" json:"name"`
 	Description string     `gorm:"column:description;type:text" json:"description"`
-	CronExpr    string     `gorm:"column:cron_expr;type:varchar(100);not null" json:"cron_expr"`
-	Enabled     bool       `gorm:"column:enabled;type:bool;not null;default:true" json:"enabled"`
-	Timeout     int        `gorm:"column:timeout;type:int;not null;default:300" json:"timeout"` // 秒
-	RetryCount  int        `gorm:"column:retry_count;type:int;not null;default:0" json:"retry_count"`
-	LockedBy    *string    `gorm:"column:locked_by;type:varchar(100)" json:"locked_by"`
-	LockedAt    *time.Time `gorm:"column:locked_at;type:timestamp(3)" json:"locked_at"`
-	ExpiredAt   *time.Time `gorm:"column:expired_at;type:timestamp(3)" json:"expired_at"`
+	Weekends    string     `gorm:"column:weekends;type:varchar(20);not null;default:'0,6'" json:"weekends"` // 休息日，逗号分隔，0为周日
 	CreatedAt   *time.Time `gorm:"column:created_at;type:timestamp(3)" json:"created_at"`
 	UpdatedAt   *time.Time `gorm:"column:updated_at;type:timestamp(3)" json:"updated_at"`
 }
 
-func (Hawthorn_task) TableName() string {
-	return "hawthorn_task"
+func (Hawthorn_calendar) TableName() string {
+	return "hawthorn_calendar"
 }
 
-type Hawthorn_task_execution struct {
-	ID          int64      `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
-	CreatedDate time.Time  `gorm:"column:created_date;type:date;not null;primaryKey" json:"created_date"`
-	TaskID      int64      `gorm:"column:task_id;type:bigint;not null" json:"task_id"`
-	NodeID      string     `gorm:"column:node_id;type:varchar(100);not null" json:"node_id"`
-	Status      string     `gorm:"column:status;type:varchar(20);not null" json:"status"` // success, failed
+// Hawthorn_calendar_day 日历的例外日期：节假日或调休工作日
+type Hawthorn_calendar_day struct {
+	ID         int64     `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	CalendarID int64     `gorm:"column:calendar_id;type:bigint;not null;uniqueIndex:idx_calendar_day" json:"calendar_id"`
+	Date       time.Time `gorm:"column:date;type:date;not null;uniqueIndex:idx_calendar_day" json:"date"`
+	DayType    string    `gorm:"column:day_type;type:varchar(20);not null" json:"day_type"` // holiday, workday
+	Name       string    `gorm:"column:name;type:varchar(100)" json:"name"`
+}
+
+func (Hawthorn_calendar_day) TableName() string {
+	return "hawthorn_calendar_day"
+}
+
+// Hawthorn_task_dependency 任务依赖，上游任务同一计划时间的执行满足条件后触发下游任务
+type Hawthorn_task_dependency struct {
+	ID         int64      `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	TaskID     int64      `gorm:"column:task_id;type:bigint;not null;uniqueIndex:idx_task_dependency" json:"task_id"`               // 下游任务
+	UpstreamID int64      `gorm:"column:upstream_id;type:bigint;not null;uniqueIndex:idx_task_dependency;index" json:"upstream_id"` // 上游任务
+	Condition  string     `gorm:"column:condition;type:varchar(20);not null;default:success" json:"condition"`                      // success, failure, any
+	CreatedAt  *time.Time `gorm:"column:created_at;type:timestamp(3);not null;default:now()" json:"created_at"`
+}
+
+func (Hawthorn_task_dependency) TableName() string {
+	return "hawthorn_task_dependency"
+}
+
+// Hawthorn_task_slot 允许并发的任务的执行槽位，每个槽位同一时间只有一个执行持有
+type Hawthorn_task_slot struct {
+	TaskID    int64     `gorm:"column:task_id;type:bigint;primaryKey;autoIncrement:false" json:"task_id"`
+	Slot      int       `gorm:"column:slot;type:int;primaryKey;autoIncrement:false" json:"slot"`
+	LockedBy  string    `gorm:"column:locked_by;type:varchar(100);not null" json:"locked_by"`
+	LockedAt  time.Time `gorm:"column:locked_at;type:timestamp(3);not null" json:"locked_at"`
+	ExpiredAt time.Time `gorm:"column:expired_at;type:timestamp(3);not null" json:"expired_at"`
+}
+
+func (Hawthorn_task_slot) TableName() string {
+	return "hawthorn_task_slot"
+}
+
+// Hawthorn_task_shard 分片任务每次触发拆分出的分片，各节点通过SKIP LOCKED抢占执行
+type Hawthorn_task_shard struct {
+	ID            int64           `gorm:"column:id;type:bigint;primaryKey;autoIncrement" json:"id"`
+	ParentTraceID string          `gorm:"column:parent_trace_id;type:varchar(64);not null;uniqueIndex:idx_task_shard" json:"parent_trace_id"` // 父执行的追踪号
+	TaskID        int64           `gorm:"column:task_id;type:bigint;not null" json:"task_id"`
+	ShardIndex    int             `gorm:"column:shard_index;type:int;not null;uniqueIndex:idx_task_shard" json:"shard_index"`
+	ShardTotal    int             `gorm:"column:shard_total;type:int;not null" json:"shard_total"`
+	ScheduledAt   *time.Time      `gorm:"column:scheduled_at;type:timestamp(3)" json:"scheduled_at"`
+	TriggerType   string          `gorm:"column:trigger_type;type:varchar(20)" json:"trigger_type"` // 父执行的触发方式
+	Params        json.RawMessage `gorm:"column:params;type:jsonb" json:"params"`
+	Status        string          `gorm:"column:status;type:varchar(20);not null;index" json:"status"` // pending, running, success, skipped, failed, timeout, cancelled
+	NodeID        string          `gorm:"column:node_id;type:varchar(100)" json:"node_id"`
+	TraceID       string          `gorm:"column:trace_id;type:varchar(64)" json:"trace_id"`      // 分片本次执行的追踪号
+	ExpiredAt     *time.Time      `gorm:"column:expired_at;type:timestamp(3)" json:"expired_at"` // 执行租约，过期后可被其他节点重新抢占
+	StartTime     *time.Time      `gorm:"column:start_time;type:timestamp(3)" json:"start_time"`
+	EndTime       *time.Time      `gorm:"column:end_time;type:timestamp(3)" json:"end_time"`
+	Attempts      []TaskAttempt   `gorm:"column:attempts;type:jsonb;serializer:json" json:"attempts"`
+	Error         string          `gorm:"column:error;type:text" json:"error"`
+	Retries       int             `gorm:"column:retries;type:int;not null;default:0" json:"retries"` // 人工重试次数
+	CreatedAt     *time.Time      `gorm:"column:created_at;type:timestamp(3);not null;default:now()" json:"created_at"`
+}
+
+func (Hawthorn_task_shard) TableName() string {
+	return "hawthorn_task_shard"
+}
+
+// Hawthorn_node 集群节点，由各节点的任务管理器定期心跳更新
+type Hawthorn_node struct {
+	NodeID      string     `gorm:"column:node_id;type:varchar(100);primaryKey" json:"node_id"`
+	IP          string     `gorm:"column:ip;type:varchar(50)" json:"ip"`
+	AppName     string     `gorm:"column:app_name;type:varchar(100)" json:"app_name"`
+	Version     string     `gorm:"column:version;type:varchar(50)" json:"version"`
+	Handlers    []string   `gorm:"column:handlers;type:jsonb;serializer:json" json:"handlers"` // 节点注册的任务函数
 	StartTime   time.Time  `gorm:"column:start_time;type:timestamp(3);not null" json:"start_time"`
-	EndTime     *time.Time `gorm:"column:end_time;type:timestamp(3)" json:"end_time"`
-	Error       string     `gorm:"column:error;type:text" json:"error"`
-	TraceID     string     `gorm:"column:trace_id;type:varchar(64)" json:"trace_id"` // 全流程追踪号
+	HeartbeatAt *time.Time `gorm:"column:heartbeat_at;type:timestamp(3);not null;default:now();index" json:"heartbeat_at"`
 }
 
-func (Hawthorn_task_execution) TableName() string {
-	return "hawthorn_task_execution"
+func (Hawthorn_node) TableName() string {
+	return "hawthorn_node"
+}
+
+// Hawthorn_task_cancel 取消请求，按追踪号匹配执行中的任务或分片，不依赖执行记录
+type Hawthorn_task_cancel struct {
+	TraceID   string     `gorm:"column:trace_id;type:varchar(64);primaryKey" json:"trace_id"`
+	TaskID    int64      `gorm:"column:task_id;type:bigint;not null" json:"task_id"`
+	CancelBy  string     `gorm:"column:cancel_by;type:varchar(50)" json:"cancel_by"`
+	CreatedAt *time.Time `gorm:"column:created_at;type:timestamp(3);not null;default:now();index" json:"created_at"`
+}
+
+func (Hawthorn_task_cancel) TableName() string {
+	return "hawthorn_task_cancel"
 }